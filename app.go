@@ -7,8 +7,11 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 )
 
 const (
@@ -16,25 +19,255 @@ const (
 	defaultCLIName = "claude"
 )
 
+// exitDiscarded is the exit status when the user quit without copying or
+// running anything, so wrapper scripts can tell; errors exit with 1.
+const exitDiscarded = 2
+
+// attachFlags collects repeated -attach paths.
+type attachFlags []string
+
+func (a *attachFlags) String() string { return strings.Join(*a, ",") }
+
+func (a *attachFlags) Set(path string) error {
+	*a = append(*a, path)
+	return nil
+}
+
 // Main is the entrypoint for the insta-assist application.
 func Main() {
 	cliFlag := flag.String("cli", defaultCLIName, "default CLI to use: claude or codex")
 	promptFlag := flag.String("prompt", "", "prompt to send (non-interactive mode)")
+	promptFileFlag := flag.String("prompt-file", "", "load the initial TUI prompt from a file")
+	var attachPaths attachFlags
+	flag.Var(&attachPaths, "attach", "append a file's contents to new prompts as context (repeatable)")
+	submitFlag := flag.Bool("submit", false, "send the -prompt-file prompt as soon as the TUI starts")
 	selectFlag := flag.Int("select", -1, "auto-select option by index (0-based, use with -prompt)")
-	outputFlag := flag.String("output", "clipboard", "output mode: clipboard, stdout, or exec")
+	topFlag := flag.Bool("top", false, "print only the top option's value to stdout and exit (for $(inst -top -prompt ...))")
+	outputFlag := flag.String("output", "clipboard", "output mode: clipboard, stdout, exec, or tsv (all options as tab-separated rows)")
 	stayOpenExecFlag := flag.Bool("stay-open-exec", false, "when executing (Ctrl+R), keep the TUI open and show output instead of exiting")
 	yoloFlag := flag.Bool("yolo", false, "start with YOLO/auto-approve enabled")
+	styleFlag := flag.String("style", styleOptions, "prompt preset: options (concise options), commands (runnable shell commands only), or answer (single best answer, never run)")
+	langFlag := flag.String("lang", "", "language for option values and descriptions, e.g. German")
+	maxOrderFlag := flag.Int("max-order", 0, "hide options with recommendation_order above N (0 = show all)")
+	clipboardFlag := flag.String("clipboard", clipboardTargetClipboard, "where copies go: clipboard, or primary (the X11/Wayland middle-click selection)")
+	execModeFlag := flag.String("exec-mode", execModeShell, "how to run selected values: shell (sh -c) or direct (split into argv, no shell)")
+	forceExecFlag := flag.Bool("force-exec", false, "ignore the exec_allow/exec_deny patterns from the config")
+	confirmFlag := flag.Bool("confirm", false, "show the full command and ask before running it (ctrl+r)")
+	stickyPromptFlag := flag.Bool("sticky-prompt", false, "start each new prompt (n, alt+enter) with the previous one for quick edits")
+	keepOpenFlag := flag.Bool("keep-open", false, "after running a command (Ctrl+R), return to the results instead of exiting")
+	copyNewlineFlag := flag.Bool("copy-newline", false, "end copied values with a newline, so pasting into a shell runs them")
+	appendFlag := flag.Bool("append", false, "add copied values to the end of the clipboard instead of replacing it")
+	pipeFlag := flag.String("pipe", "", "shell command that receives the selected value on stdin when | is pressed")
+	preferEmbeddedFlag := flag.Bool("prefer-embedded-schema", false, "ignore options.schema.json files on disk and use the built-in schema")
+	continueOnErrorFlag := flag.Bool("continue-on-error", false, "when running several marked options, keep going after a failure")
+	maxOutputFlag := flag.Int("max-output", defaultMaxOutputBytes, "maximum bytes of CLI output to capture")
+	promptMaxFlag := flag.Int("prompt-max-chars", 0, "refuse to send prompts longer than this many characters (0 = no limit)")
+	noCacheFlag := flag.Bool("no-cache", false, "always query the CLI instead of reusing cached responses")
+	cacheTTLFlag := flag.Duration("cache-ttl", defaultCacheTTL, "how long cached responses are reused")
+	parseModeFlag := flag.String("parse", parseModeAuto, "output framing: auto (concatenated JSON objects) or ndjson (one JSON object per line)")
+	rawFlag := flag.Bool("raw", false, "skip the options schema and show/copy/run the whole reply (toggle in the TUI with ctrl+g)")
+	maxRetriesParseFlag := flag.Int("max-retries-parse", 0, "re-prompt up to N times asking for JSON only when a response can't be parsed")
+	maxRetriesEmptyFlag := flag.Int("max-retries-empty", 0, "re-prompt up to N times asking for at least one option when a reply has none")
+	maxValueWidthFlag := flag.Int("max-value-width", 0, "truncate displayed option values to this many columns with … (0 = off)")
+	sessionFlag := flag.Bool("session", false, "continue the CLI's previous session for each new prompt so context is shared")
+	daemonFlag := flag.Bool("daemon", false, "stay resident and accept prompts from -trigger over a unix socket")
+	triggerFlag := flag.Bool("trigger", false, "ask a running -daemon for a value (optionally seeded with -prompt) and print it")
+	serveFlag := flag.String("serve", "", "serve POST /prompt on this address (e.g. 127.0.0.1:8080) and return options as JSON, without the TUI")
+	socketFlag := flag.String("socket", "", "unix socket path for -daemon/-trigger (default: $XDG_RUNTIME_DIR/instassist.sock)")
+	timeoutFlag := flag.Duration("timeout", defaultCLITimeout, "how long a CLI call may run, for every CLI (overrides per-CLI config timeouts)")
+	tickFlag := flag.Duration("tick", defaultTickInterval, "spinner frame interval (e.g. 200ms over SSH or on battery)")
+	numberedFlag := flag.Bool("numbered", false, "prefix each option with its 1-based index")
+	explainFlag := flag.Bool("explain", false, "show the model's reasoning under the options when the CLI reports it (toggle with w)")
+	autoSelectFlag := flag.Bool("auto-select", false, "select the top recommended option and mark it as suggested (nothing is run)")
+	markdownFlag := flag.Bool("markdown", false, "render the selected option's description and explanation as markdown (lists, bold, code) in the e detail view")
+	presentFlag := flag.Bool("present", false, "presentation mode: larger selection highlight, spaced options, no key hints")
+	noColorFlag := flag.Bool("no-color", false, "disable colors and inline code styling (also set by NO_COLOR)")
+	asciiFlag := flag.Bool("ascii", false, "use ASCII markers instead of emoji (auto-enabled on dumb or non-UTF-8 terminals)")
+	profileFlag := flag.String("profile", "", "named profile from the config file to apply")
+	configFlag := flag.String("config", defaultConfigPath(), "path to JSON config file")
 	versionFlag := flag.Bool("version", false, "print version and exit")
-	flag.Parse()
+	transcriptFlag := flag.String("transcript", "", "on exit, write every prompt, reply, and copied or run value of the session to this file")
+	timingsFlag := flag.Bool("timings", false, "on exit, print time spent in CLI subprocesses, parsing and idle to stderr")
+	if err := parseFlagsWithEnv(flag.CommandLine, os.Getenv(envFlagsVar), os.Args[1:]); err != nil {
+		log.Fatalf("error: %v", err)
+	}
 
 	if *versionFlag {
 		fmt.Printf("insta-assist version %s\n", version)
 		os.Exit(0)
 	}
+	if *topFlag {
+		// Print the recommended option, whatever sort_by, and nothing else.
+		*outputFlag, *selectFlag = "stdout", selectRecommended
+	}
+
+	cfg, err := loadConfig(*configFlag)
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+	if *profileFlag != "" {
+		if cfg, err = cfg.withProfile(*profileFlag); err != nil {
+			log.Fatalf("config error: %v", err)
+		}
+	}
+	// Flags given explicitly on the command line win over the config file.
+	asciiSet := false
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "cli":
+			cfg.DefaultCLI = *cliFlag
+		case "style":
+			cfg.Style = *styleFlag
+		case "lang":
+			cfg.Language = *langFlag
+		case "max-order":
+			cfg.MaxOrder = *maxOrderFlag
+		case "clipboard":
+			cfg.Clipboard = *clipboardFlag
+		case "exec-mode":
+			cfg.ExecMode = *execModeFlag
+		case "confirm":
+			cfg.Confirm = *confirmFlag
+		case "sticky-prompt":
+			cfg.StickyPrompt = *stickyPromptFlag
+		case "keep-open":
+			cfg.KeepOpen = *keepOpenFlag
+		case "copy-newline":
+			cfg.CopyNewline = *copyNewlineFlag
+		case "append":
+			cfg.Append = *appendFlag
+		case "pipe":
+			cfg.Pipe = *pipeFlag
+		case "prefer-embedded-schema":
+			cfg.PreferEmbeddedSchema = *preferEmbeddedFlag
+		case "continue-on-error":
+			cfg.ContinueOnError = *continueOnErrorFlag
+		case "max-output":
+			cfg.MaxOutputBytes = *maxOutputFlag
+		case "prompt-max-chars":
+			cfg.PromptMaxChars = *promptMaxFlag
+		case "no-cache":
+			cfg.NoCache = *noCacheFlag
+		case "cache-ttl":
+			cfg.CacheTTL = duration(*cacheTTLFlag)
+		case "parse":
+			cfg.ParseMode = *parseModeFlag
+		case "raw":
+			cfg.Raw = *rawFlag
+		case "max-retries-parse":
+			cfg.MaxParseRetries = *maxRetriesParseFlag
+		case "max-retries-empty":
+			cfg.MaxEmptyRetries = *maxRetriesEmptyFlag
+		case "max-value-width":
+			cfg.MaxValueWidth = *maxValueWidthFlag
+		case "session":
+			cfg.Session = *sessionFlag
+		case "timeout":
+			cfg.Timeout = duration(*timeoutFlag)
+			cfg.Timeouts = nil
+		case "tick":
+			cfg.TickInterval = duration(*tickFlag)
+		case "markdown":
+			cfg.Markdown = *markdownFlag
+		case "numbered":
+			cfg.Numbered = *numberedFlag
+		case "auto-select":
+			cfg.AutoSelect = *autoSelectFlag
+		case "explain":
+			cfg.Explain = *explainFlag
+		case "present":
+			cfg.Present = *presentFlag
+		case "no-color":
+			cfg.NoColor = *noColorFlag
+		case "ascii":
+			cfg.ASCII = *asciiFlag
+			asciiSet = true
+		}
+	})
+	if cfg.ExecMode != "" && cfg.ExecMode != execModeShell && cfg.ExecMode != execModeDirect {
+		log.Fatalf("unknown exec mode %q (supported: shell, direct)", cfg.ExecMode)
+	}
+	if err := validateExecTemplate(cfg.ExecTemplate); err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	if err := useClipboardTarget(cfg.Clipboard); err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	if !validParseMode(cfg.ParseMode) {
+		log.Fatalf("unknown parse mode %q (supported: auto, ndjson)", cfg.ParseMode)
+	}
+	if !validSortStrategy(cfg.SortBy) {
+		log.Fatalf("unknown sort_by %q (supported: by-order, by-index)", cfg.SortBy)
+	}
+	if !validStyle(cfg.Style) {
+		log.Fatalf("unknown style %q (supported: options, commands, answer)", cfg.Style)
+	}
+	if cfg.DefaultCLI == "" {
+		cfg.DefaultCLI = detectCLI(cfg.CLIEnv, os.Getenv)
+	}
+	if cfg.DefaultCLI == "" {
+		cfg.DefaultCLI = defaultCLIName
+	}
+	if cfg.ASCII || (!asciiSet && asciiTerminal()) {
+		icons = asciiIcons
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		cfg.NoColor = true
+	}
+	if cfg.NoColor {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+
+	initialPrompt := ""
+	if *promptFileFlag != "" {
+		if initialPrompt, err = readPromptFile(*promptFileFlag); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+	} else if *submitFlag {
+		log.Fatalf("error: -submit requires -prompt-file")
+	}
+
+	if *forceExecFlag {
+		cfg.ExecAllow, cfg.ExecDeny = nil, nil
+	}
+	policy, err := newExecPolicy(cfg.ExecAllow, cfg.ExecDeny)
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+
+	var attachments []attachment
+	for _, path := range attachPaths {
+		a, err := readAttachment(path)
+		if err != nil {
+			log.Fatalf("error: %v", err)
+		}
+		attachments = append(attachments, a)
+	}
+
+	socketPath := *socketFlag
+	if socketPath == "" {
+		socketPath = defaultSocketPath()
+	}
+	if *triggerFlag {
+		value, err := sendTrigger(socketPath, *promptFlag)
+		if err != nil {
+			log.Fatalf("trigger error: %v", err)
+		}
+		if value == "" {
+			os.Exit(exitDiscarded)
+		}
+		fmt.Println(value)
+		return
+	}
+
+	if *serveFlag != "" {
+		runServe(*serveFlag, cfg)
+		return
+	}
 
 	// Non-interactive mode
-	if *promptFlag != "" {
-		runNonInteractive(*cliFlag, *promptFlag, *selectFlag, *outputFlag, *yoloFlag)
+	if *promptFlag != "" && !*daemonFlag {
+		runNonInteractive(cfg.DefaultCLI, *promptFlag, attachments, policy, *selectFlag, *outputFlag, *yoloFlag, cfg)
 		return
 	}
 
@@ -47,14 +280,69 @@ func Main() {
 		}
 		prompt := strings.TrimSpace(string(data))
 		if prompt != "" {
-			runNonInteractive(*cliFlag, prompt, *selectFlag, *outputFlag, *yoloFlag)
+			runNonInteractive(cfg.DefaultCLI, prompt, attachments, policy, *selectFlag, *outputFlag, *yoloFlag, cfg)
 			return
 		}
 	}
 
-	// Interactive TUI mode
-	m := newModel(*cliFlag, *stayOpenExecFlag, *yoloFlag)
-	if _, err := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion()).Run(); err != nil {
-		log.Fatalf("error: %v", err)
+	// Without a capable terminal the TUI's escape sequences come out as
+	// garbage, so read one plain prompt line and answer it non-interactively.
+	// -top never opens the TUI either.
+	if !*daemonFlag && (*topFlag || limitedTerminal(os.Getenv("TERM"), isTerminal(os.Stdout))) {
+		prompt := initialPrompt
+		if prompt == "" {
+			if prompt, err = readPlainPrompt(os.Stdin, os.Stderr); err != nil {
+				log.Fatalf("error: %v", err)
+			}
+		}
+		runNonInteractive(cfg.DefaultCLI, prompt, attachments, policy, *selectFlag, *outputFlag, *yoloFlag, cfg)
+		return
+	}
+
+	// Interactive TUI mode. Bracketed paste is on by default in Bubble Tea, so
+	// multi-line pastes arrive as a single KeyMsg with Paste set. Bubble Tea
+	// turns SIGINT/SIGTERM into a quit, so Run returns and teardown still runs.
+	if *daemonFlag {
+		// Running a command shouldn't end a resident session.
+		cfg.KeepOpen = true
+	}
+	m := newModel(cfg.DefaultCLI, *stayOpenExecFlag, *yoloFlag, cfg)
+	m.daemon = *daemonFlag
+	m.attachments = attachments
+	m.execPolicy = policy
+	m.favoritesPath = favoritesPathFor(*configFlag)
+	if initialPrompt != "" {
+		m.input.SetValue(initialPrompt)
+		m.autoSubmit = *submitFlag
+	}
+	program := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion(), tea.WithReportFocus())
+	if *daemonFlag {
+		ln, err := listenTrigger(socketPath)
+		if err != nil {
+			fatalf("daemon error: %v", err)
+		}
+		onTeardown(func() {
+			ln.Close()
+			_ = os.Remove(socketPath)
+		})
+		go serveTriggers(ln, program.Send)
+	}
+	started := time.Now()
+	final, err := program.Run()
+	if err != nil {
+		fatalf("error: %v", err)
+	}
+	fm, ok := final.(model)
+	if ok && *transcriptFlag != "" {
+		if err := writeTranscript(*transcriptFlag, started, fm.transcript); err != nil {
+			log.Printf("warning: %v", err)
+		}
+	}
+	teardown()
+	if ok && *timingsFlag {
+		fmt.Fprint(os.Stderr, fm.timings.summary(time.Since(started)))
+	}
+	if ok && !fm.acted {
+		os.Exit(exitDiscarded)
 	}
 }
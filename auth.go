@@ -0,0 +1,52 @@
+package instassist
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// authFailure is how a CLI says it isn't logged in, and what fixes it.
+type authFailure struct {
+	patterns []*regexp.Regexp
+	fix      string
+}
+
+// authFailures maps a CLI name to the messages it prints when it has no
+// usable credentials. The patterns are matched against its combined output
+// after a failed run.
+var authFailures = map[string]authFailure{
+	"claude": {
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)invalid api key`),
+			regexp.MustCompile(`(?i)please run /login`),
+			regexp.MustCompile(`(?i)oauth token (has )?expired`),
+			regexp.MustCompile(`(?i)not logged in`),
+			regexp.MustCompile(`"type"\s*:\s*"authentication_error"`),
+		},
+		fix: "run `claude`, then /login",
+	},
+	"codex": {
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)not logged in`),
+			regexp.MustCompile("(?i)run `?codex login`?"),
+			regexp.MustCompile(`(?i)401 unauthorized`),
+			regexp.MustCompile(`(?i)incorrect api key|invalid_api_key`),
+		},
+		fix: "run `codex login`",
+	},
+}
+
+// authHint returns a friendly "not authenticated" message when output from
+// a failed cliName run matches one of its known login errors, or "".
+func authHint(cliName, output string) string {
+	failure, ok := authFailures[cliName]
+	if !ok {
+		return ""
+	}
+	for _, re := range failure.patterns {
+		if re.MatchString(output) {
+			return fmt.Sprintf("%s not authenticated — %s", cliName, failure.fix)
+		}
+	}
+	return ""
+}
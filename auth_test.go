@@ -0,0 +1,22 @@
+package instassist
+
+import "testing"
+
+func TestAuthHint(t *testing.T) {
+	tests := []struct {
+		cli, output string
+		want        string
+	}{
+		{"claude", "Invalid API key · Please run /login", "claude not authenticated — run `claude`, then /login"},
+		{"claude", `{"type":"error","error":{"type":"authentication_error","message":"OAuth token has expired."}}`, "claude not authenticated — run `claude`, then /login"},
+		{"codex", "Error: Not logged in. Please run `codex login`.", "codex not authenticated — run `codex login`"},
+		{"codex", "stream error: unexpected status 401 Unauthorized", "codex not authenticated — run `codex login`"},
+		{"claude", "Error: rate limited", ""},
+		{"gemini", "not logged in", ""},
+	}
+	for _, tt := range tests {
+		if got := authHint(tt.cli, tt.output); got != tt.want {
+			t.Errorf("authHint(%q, %q) = %q, want %q", tt.cli, tt.output, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package instassist
+
+import (
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// urlOpenedMsg reports whether the browser launcher accepted the URL.
+type urlOpenedMsg struct {
+	url string
+	err error
+}
+
+// looksLikeURL reports whether value is a single http(s) URL, the only kind
+// the o key hands to the browser.
+func looksLikeURL(value string) bool {
+	value = strings.TrimSpace(value)
+	if strings.ContainsAny(value, " \t\n") {
+		return false
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// browserCommand returns the platform's "open this in the default app"
+// command for target.
+func browserCommand(target string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", target)
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		return exec.Command("xdg-open", target)
+	}
+}
+
+func openURL(target string) tea.Cmd {
+	return func() tea.Msg {
+		err := browserCommand(target).Run()
+		return urlOpenedMsg{url: target, err: err}
+	}
+}
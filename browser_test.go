@@ -0,0 +1,23 @@
+package instassist
+
+import "testing"
+
+func TestLooksLikeURL(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"https://pkg.go.dev/net/url", true},
+		{"  http://localhost:8080/docs ", true},
+		{"ftp://example.com/file", false},
+		{"example.com", false},
+		{"ls -la", false},
+		{"see https://example.com", false},
+		{"https://", false},
+	}
+	for _, tt := range tests {
+		if got := looksLikeURL(tt.value); got != tt.want {
+			t.Errorf("looksLikeURL(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package instassist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultCacheTTL = 24 * time.Hour
+
+// responseCache stores raw CLI output on disk, keyed by CLI and full prompt,
+// so repeating a prompt doesn't pay for another model call.
+type responseCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// newResponseCache returns nil when no user cache directory is available,
+// which disables caching.
+func newResponseCache(ttl time.Duration) *responseCache {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return nil
+	}
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	return &responseCache{dir: filepath.Join(base, "instassist", "responses"), ttl: ttl}
+}
+
+// cacheKey identifies a response by the CLI, the prompt sent, and whether it
+// ran with permission checks skipped (yolo), since that can change the reply.
+func cacheKey(cliName, fullPrompt string, yolo bool) string {
+	mode := "safe"
+	if yolo {
+		mode = "yolo"
+	}
+	sum := sha256.Sum256([]byte(cliName + "\x00" + mode + "\x00" + fullPrompt))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *responseCache) get(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	path := filepath.Join(c.dir, key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	if time.Since(info.ModTime()) > c.ttl {
+		_ = os.Remove(path)
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (c *responseCache) put(key string, output []byte) error {
+	if c == nil {
+		return nil
+	}
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(c.dir, key), output, 0o600)
+}
@@ -0,0 +1,57 @@
+package instassist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheRoundTrip(t *testing.T) {
+	c := &responseCache{dir: filepath.Join(t.TempDir(), "responses"), ttl: time.Hour}
+	key := cacheKey("claude", "list files", false)
+	if _, ok := c.get(key); ok {
+		t.Fatal("expected miss on empty cache")
+	}
+	if err := c.put(key, []byte("output")); err != nil {
+		t.Fatalf("put returned error: %v", err)
+	}
+	got, ok := c.get(key)
+	if !ok || string(got) != "output" {
+		t.Fatalf("expected cached output, got %q ok=%v", got, ok)
+	}
+	if cacheKey("codex", "list files", false) == key {
+		t.Fatal("expected cache key to depend on the CLI")
+	}
+	if cacheKey("claude", "list files", true) == key {
+		t.Fatal("expected cache key to depend on yolo")
+	}
+}
+
+func TestResponseCacheExpires(t *testing.T) {
+	c := &responseCache{dir: t.TempDir(), ttl: time.Minute}
+	key := cacheKey("claude", "list files", false)
+	if err := c.put(key, []byte("output")); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Minute)
+	if err := os.Chtimes(filepath.Join(c.dir, key), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.get(key); ok {
+		t.Fatal("expected expired entry to miss")
+	}
+	if _, err := os.Stat(filepath.Join(c.dir, key)); !os.IsNotExist(err) {
+		t.Fatal("expected expired entry to be removed")
+	}
+}
+
+func TestNilResponseCacheIsDisabled(t *testing.T) {
+	var c *responseCache
+	if _, ok := c.get("k"); ok {
+		t.Fatal("expected nil cache to miss")
+	}
+	if err := c.put("k", []byte("v")); err != nil {
+		t.Fatalf("expected nil cache put to be a no-op, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package instassist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/atotto/clipboard"
+)
+
+// Clipboard access, swappable in tests.
+var (
+	readClipboard  = clipboard.ReadAll
+	writeClipboard = clipboard.WriteAll
+)
+
+const (
+	clipboardTargetClipboard = "clipboard"
+	clipboardTargetPrimary   = "primary"
+)
+
+// selectionTool is a command pair that reads and writes the X11/Wayland
+// primary selection, the one middle-click pastes.
+type selectionTool struct {
+	write, read []string
+}
+
+// primarySelectionTools lists the helpers that can reach the primary
+// selection, Wayland first when a Wayland session is running.
+func primarySelectionTools(getenv func(string) string) []selectionTool {
+	x11 := []selectionTool{
+		{write: []string{"xsel", "--primary", "--input"}, read: []string{"xsel", "--primary", "--output"}},
+		{write: []string{"xclip", "-selection", "primary", "-in"}, read: []string{"xclip", "-selection", "primary", "-out"}},
+	}
+	wayland := selectionTool{write: []string{"wl-copy", "--primary"}, read: []string{"wl-paste", "--primary", "--no-newline"}}
+	if getenv("WAYLAND_DISPLAY") != "" {
+		return append([]selectionTool{wayland}, x11...)
+	}
+	return append(x11, wayland)
+}
+
+// useClipboardTarget points readClipboard/writeClipboard at target. The
+// primary selection only exists on X11 and Wayland desktops and needs one of
+// xsel, xclip or wl-clipboard installed.
+func useClipboardTarget(target string) error {
+	switch target {
+	case "", clipboardTargetClipboard:
+		readClipboard, writeClipboard = clipboard.ReadAll, clipboard.WriteAll
+		return nil
+	case clipboardTargetPrimary:
+	default:
+		return fmt.Errorf("unknown clipboard %q (supported: clipboard, primary)", target)
+	}
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		return fmt.Errorf("-clipboard primary is not supported on %s", runtime.GOOS)
+	}
+	for _, tool := range primarySelectionTools(os.Getenv) {
+		if _, err := exec.LookPath(tool.write[0]); err != nil {
+			continue
+		}
+		if _, err := exec.LookPath(tool.read[0]); err != nil {
+			continue
+		}
+		readClipboard = func() (string, error) {
+			out, err := exec.Command(tool.read[0], tool.read[1:]...).Output()
+			return string(out), err
+		}
+		writeClipboard = func(s string) error {
+			cmd := exec.Command(tool.write[0], tool.write[1:]...)
+			cmd.Stdin = strings.NewReader(s)
+			return cmd.Run()
+		}
+		return nil
+	}
+	return errors.New("-clipboard primary needs xsel, xclip or wl-clipboard installed")
+}
+
+// copyValue writes value to the clipboard unless it already holds it, so
+// platforms that notify on every write don't fire for a repeat copy. already
+// reports the skipped write; a failed read just falls through to the write.
+func copyValue(value string) (already bool, err error) {
+	if current, err := readClipboard(); err == nil && current == value {
+		return true, nil
+	}
+	return false, writeClipboard(value)
+}
+
+const defaultAppendSeparator = "\n"
+
+// withCopyNewline ends value with a newline for -copy-newline, so pasting it
+// into a shell runs it straight away. A value that already ends in one is
+// left alone.
+func withCopyNewline(value string, on bool) string {
+	if !on || strings.HasSuffix(value, "\n") {
+		return value
+	}
+	return value + "\n"
+}
+
+// appendValue adds value to the end of the clipboard after sep, for building
+// a list across copies. An empty or unreadable clipboard just gets value.
+// When value ends in a newline (-copy-newline), the clipboard's own trailing
+// newline is dropped first so it doesn't stack with sep into a blank line.
+func appendValue(value, sep string) error {
+	current, err := readClipboard()
+	if err != nil || current == "" {
+		return writeClipboard(value)
+	}
+	if strings.HasSuffix(value, "\n") {
+		current = strings.TrimSuffix(current, "\n")
+	}
+	return writeClipboard(current + sep + value)
+}
+
+// runCopyHook starts the on_copy command with value on stdin and in
+// $INSTASSIST_VALUE and returns without waiting, so a slow hook never holds
+// up the UI. Its output is discarded to keep it off the terminal.
+func runCopyHook(command, value string) error {
+	if strings.TrimSpace(command) == "" {
+		return nil
+	}
+	cmd := shellCommand(context.Background(), command)
+	cmd.Stdin = strings.NewReader(value)
+	cmd.Env = append(os.Environ(), "INSTASSIST_VALUE="+value)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
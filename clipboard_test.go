@@ -0,0 +1,103 @@
+package instassist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopyValueSkipsIdenticalWrite(t *testing.T) {
+	board, readErr := "", error(nil)
+	writes := 0
+	defer func(r func() (string, error), w func(string) error) {
+		readClipboard, writeClipboard = r, w
+	}(readClipboard, writeClipboard)
+	readClipboard = func() (string, error) { return board, readErr }
+	writeClipboard = func(s string) error {
+		writes++
+		board = s
+		return nil
+	}
+
+	if already, err := copyValue("ls"); already || err != nil || writes != 1 {
+		t.Fatalf("expected a first write, got already=%v err=%v writes=%d", already, err, writes)
+	}
+	if already, err := copyValue("ls"); !already || err != nil || writes != 1 {
+		t.Fatalf("expected the repeat copy to be skipped, got already=%v writes=%d", already, writes)
+	}
+
+	readErr = nil
+	if err := appendValue("pwd", "\n"); err != nil || board != "ls\npwd" {
+		t.Fatalf("expected pwd appended, got %q (err %v)", board, err)
+	}
+
+	readErr = errors.New("no clipboard reader")
+	if already, _ := copyValue("ls"); already || writes != 3 {
+		t.Fatalf("expected a failed read to fall back to writing, got writes=%d", writes)
+	}
+}
+
+func TestRunCopyHookPassesValue(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "hook")
+	t.Setenv("OUT", out)
+	if err := runCopyHook(`cat > "$OUT.tmp"; printf '|%s' "$INSTASSIST_VALUE" >> "$OUT.tmp"; mv "$OUT.tmp" "$OUT"`, "ls -la"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := os.ReadFile(out)
+		if err == nil {
+			if got := string(data); got != "ls -la|ls -la" {
+				t.Fatalf("hook saw %q, want the value on stdin and in the environment", got)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("hook did not run")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPrimarySelectionToolsPreferWayland(t *testing.T) {
+	env := map[string]string{}
+	getenv := func(k string) string { return env[k] }
+	if tools := primarySelectionTools(getenv); tools[0].write[0] != "xsel" {
+		t.Fatalf("expected xsel first on X11, got %v", tools[0].write)
+	}
+	env["WAYLAND_DISPLAY"] = "wayland-0"
+	if tools := primarySelectionTools(getenv); tools[0].write[0] != "wl-copy" || tools[0].read[0] != "wl-paste" {
+		t.Fatalf("expected wl-clipboard first on Wayland, got %v", tools[0])
+	}
+}
+
+func TestUseClipboardTargetRejectsUnknown(t *testing.T) {
+	defer func(r func() (string, error), w func(string) error) {
+		readClipboard, writeClipboard = r, w
+	}(readClipboard, writeClipboard)
+	if err := useClipboardTarget("secondary"); err == nil {
+		t.Fatal("expected an unknown clipboard target to be refused")
+	}
+	if err := useClipboardTarget(clipboardTargetClipboard); err != nil {
+		t.Fatalf("expected the default clipboard to be accepted: %v", err)
+	}
+}
+
+func TestWithCopyNewline(t *testing.T) {
+	tests := []struct {
+		value string
+		on    bool
+		want  string
+	}{
+		{"ls -la", false, "ls -la"},
+		{"ls -la", true, "ls -la\n"},
+		{"echo a\necho b\n", true, "echo a\necho b\n"},
+	}
+	for _, tt := range tests {
+		if got := withCopyNewline(tt.value, tt.on); got != tt.want {
+			t.Errorf("withCopyNewline(%q, %v) = %q, want %q", tt.value, tt.on, got, tt.want)
+		}
+	}
+}
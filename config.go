@@ -0,0 +1,541 @@
+package instassist
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const configFileName = "config.json"
+
+type config struct {
+	// DefaultCLI is used when -cli is not given.
+	DefaultCLI string `json:"default_cli"`
+	// CLIEnv picks the default CLI from credentials in the environment when
+	// neither -cli nor DefaultCLI is set. It is checked before the built-in
+	// defaultCLIEnv rules.
+	CLIEnv []envCLI `json:"cli_env"`
+	// PromptPrefix is prepended to every new prompt (not to refinements).
+	PromptPrefix string `json:"prompt_prefix"`
+	// Style is the prompt preset: "options" (default), "commands" or "answer".
+	Style string `json:"style"`
+	// Language asks for option values and descriptions in this language.
+	Language string `json:"language"`
+	// Schema points at an options schema file, bypassing the usual lookup.
+	Schema string `json:"schema"`
+	// PreferEmbeddedSchema ignores options.schema.json files found on disk.
+	PreferEmbeddedSchema bool `json:"prefer_embedded_schema"`
+
+	// PromptTemplates maps a CLI name to a prompt template used instead of the
+	// built-in instructions. "{{prompt}}" is replaced with the user prompt.
+	PromptTemplates map[string]string `json:"prompt_templates"`
+
+	// MaxOrder hides options whose recommendation_order exceeds it (0 = off).
+	MaxOrder int `json:"max_order"`
+	// DropUnordered also hides options without a recommendation_order when
+	// MaxOrder is set.
+	DropUnordered bool `json:"drop_unordered"`
+
+	// ExecMode is "shell" (default, sh -c) or "direct" to split the value into
+	// argv and run it without a shell.
+	ExecMode string `json:"exec_mode"`
+
+	// ExecTemplate wraps every value that is run, with "{{value}}" replaced
+	// by it, e.g. "time {{value}}".
+	ExecTemplate string `json:"exec_template"`
+
+	// ScriptFileBytes is the value length above which shell values are run
+	// from a temporary script file instead of as an argument (default 32 KiB,
+	// negative to never).
+	ScriptFileBytes int `json:"script_file_bytes"`
+
+	// Confirm shows the full command before running it and waits for y.
+	Confirm bool `json:"confirm"`
+
+	// ExecDeny lists regular expressions for values that must not be run
+	// without an explicit override, e.g. "rm\\s+-rf". When ExecAllow is set,
+	// values matching none of its patterns are refused too.
+	ExecDeny  []string `json:"exec_deny"`
+	ExecAllow []string `json:"exec_allow"`
+
+	// StickyPrompt starts each new prompt pre-filled with the previous one.
+	StickyPrompt bool `json:"sticky_prompt"`
+
+	// KeepOpen returns to the results after running a command instead of
+	// exiting.
+	KeepOpen bool `json:"keep_open"`
+
+	// Append makes copies add to the clipboard after AppendSeparator (default
+	// a newline) instead of replacing it.
+	Append          bool   `json:"append"`
+	AppendSeparator string `json:"append_separator"`
+
+	// CopyNewline ends every copied value with a newline.
+	CopyNewline bool `json:"copy_newline"`
+
+	// Clipboard is "clipboard" (default) or "primary" to copy into the X11/
+	// Wayland primary selection instead.
+	Clipboard string `json:"clipboard"`
+
+	// Pipe is a shell command that receives the selected value on stdin when
+	// "|" is pressed.
+	Pipe string `json:"pipe"`
+
+	// OnCopy is a shell command started after every successful copy, with the
+	// value on stdin and in $INSTASSIST_VALUE. It is not waited for.
+	OnCopy string `json:"on_copy"`
+
+	// ContinueOnError keeps running the remaining marked commands after one
+	// fails.
+	ContinueOnError bool `json:"continue_on_error"`
+
+	// Postprocess is a shell command the raw CLI output is piped through
+	// before parsing; its stdout is parsed instead. Failures fall back to the
+	// raw output.
+	Postprocess string `json:"postprocess"`
+
+	// ParseMode is "auto" (default) or "ndjson" for CLIs that stream one
+	// JSON object per line.
+	ParseMode string `json:"parse_mode"`
+
+	// SortBy is the option order: "by-order" (recommendation_order, the
+	// default) or "by-index" (as listed in the reply).
+	SortBy string `json:"sort_by"`
+
+	// Raw skips the options schema and shows the CLI's reply as-is.
+	Raw bool `json:"raw"`
+
+	// MaxParseRetries re-sends the prompt up to this many times when the
+	// reply can't be parsed, asking for JSON only.
+	MaxParseRetries int `json:"max_parse_retries"`
+
+	// MaxEmptyRetries re-sends the prompt up to this many times when the
+	// reply is valid but has no options, asking for at least one.
+	MaxEmptyRetries int `json:"max_empty_retries"`
+
+	// Timeout bounds each CLI call (default 5m). Timeouts overrides it per
+	// CLI name, e.g. {"codex": "3m"}.
+	Timeout  duration            `json:"timeout"`
+	Timeouts map[string]duration `json:"timeouts"`
+
+	// PromptWarnChars warns when the assembled prompt is longer (default
+	// 100000); PromptMaxChars refuses to send it (0 = no limit).
+	// PromptLimits overrides both per CLI name, e.g. {"codex": {"max": 200000}}.
+	PromptWarnChars int                    `json:"prompt_warn_chars"`
+	PromptMaxChars  int                    `json:"prompt_max_chars"`
+	PromptLimits    map[string]promptLimit `json:"prompt_limits"`
+
+	// TTYCLIs lists CLIs to run on a pseudo-terminal (Linux only), for CLIs
+	// that hang or change their output when not attached to one.
+	TTYCLIs []string `json:"tty_clis"`
+
+	// MaxOutputBytes caps how much CLI output is kept (default 1 MiB).
+	MaxOutputBytes int `json:"max_output_bytes"`
+
+	// NoCache disables the on-disk response cache.
+	NoCache bool `json:"no_cache"`
+	// CacheTTL is how long cached responses are reused, e.g. "12h".
+	CacheTTL duration `json:"cache_ttl"`
+
+	// MaxValueWidth truncates displayed option values to this many columns
+	// with "…" (0 = off). Copy and exec use the full value.
+	MaxValueWidth int `json:"max_value_width"`
+
+	// Session makes new prompts continue the previous CLI session, so
+	// successive prompts share context.
+	Session bool `json:"session"`
+
+	// HideInputHint hides the "responses will be parsed into selectable
+	// options" line under the prompt box.
+	HideInputHint bool `json:"hide_input_hint"`
+
+	// TickInterval is the spinner frame interval, e.g. "200ms" (default 80ms).
+	TickInterval duration `json:"tick_interval"`
+
+	// Numbered prefixes each option with its 1-based index.
+	Numbered bool `json:"numbered"`
+
+	// ExtraFields lists option fields beyond the schema's to show after the
+	// description, e.g. [{"field": "risk", "label": "Risk"}].
+	ExtraFields []extraField `json:"extra_fields"`
+
+	// ShowOrder set to false never shows recommendation_order in the list,
+	// even in the v detail view; sorting still uses it. Unset shows it.
+	ShowOrder *bool `json:"show_order"`
+
+	// Explain shows the model's reasoning under the options when the CLI
+	// reports it.
+	Explain bool `json:"explain"`
+
+	// AutoSelect selects the top recommendation and tags it as suggested,
+	// without running it.
+	AutoSelect bool `json:"auto_select"`
+
+	// Markdown renders the selected option's description and explanation as
+	// markdown in the detail view.
+	Markdown bool `json:"markdown"`
+
+	// Present enables presentation styling: bolder selection, spaced
+	// options, and no key hints.
+	Present bool `json:"present"`
+
+	// NoColor disables colors and inline code styling.
+	NoColor bool `json:"no_color"`
+
+	// ASCII replaces emoji in the UI with plain-text markers.
+	ASCII bool `json:"ascii"`
+
+	// StoreFullPrompt makes transcripts record the full prompt sent to the
+	// CLI, instructions included, instead of the text the user typed.
+	StoreFullPrompt bool `json:"store_full_prompt"`
+
+	// ServeCLIs lists the CLIs -serve may run (default: claude, codex).
+	ServeCLIs []string `json:"serve_clis"`
+
+	// Profiles are named overlays selected with -profile.
+	Profiles map[string]config `json:"profiles"`
+}
+
+// envCLI selects CLI when the environment variable Env is non-empty.
+type envCLI struct {
+	Env string `json:"env"`
+	CLI string `json:"cli"`
+}
+
+var defaultCLIEnv = []envCLI{
+	{Env: "ANTHROPIC_API_KEY", CLI: "claude"},
+	{Env: "CLAUDE_CODE_OAUTH_TOKEN", CLI: "claude"},
+	{Env: "OPENAI_API_KEY", CLI: "codex"},
+	{Env: "CODEX_API_KEY", CLI: "codex"},
+}
+
+// detectCLI returns the CLI of the first rule whose variable is set, or "".
+func detectCLI(rules []envCLI, getenv func(string) string) string {
+	for _, set := range [][]envCLI{rules, defaultCLIEnv} {
+		for _, r := range set {
+			if r.Env != "" && getenv(r.Env) != "" {
+				return r.CLI
+			}
+		}
+	}
+	return ""
+}
+
+// envFlagsVar holds default flags, e.g. INSTASSIST_FLAGS="-cli codex -timeout 2m".
+const envFlagsVar = "INSTASSIST_FLAGS"
+
+// parseFlagsWithEnv parses the flags in env and then args into fs, so a flag
+// given on the command line wins over the same flag in the environment.
+func parseFlagsWithEnv(fs *flag.FlagSet, env string, args []string) error {
+	if strings.TrimSpace(env) != "" {
+		envArgs, err := splitArgs(env)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", envFlagsVar, err)
+		}
+		if err := fs.Parse(envArgs); err != nil {
+			return fmt.Errorf("parse %s: %w", envFlagsVar, err)
+		}
+		if fs.NArg() > 0 {
+			return fmt.Errorf("parse %s: unexpected argument %q", envFlagsVar, fs.Arg(0))
+		}
+	}
+	return fs.Parse(args)
+}
+
+const defaultCLITimeout = 5 * time.Minute
+
+// cliTimeout returns how long a call to the named CLI may run: its entry in
+// perCLI, else fallback, else defaultCLITimeout.
+func cliTimeout(name string, fallback duration, perCLI map[string]duration) time.Duration {
+	if d := perCLI[strings.ToLower(name)]; d > 0 {
+		return time.Duration(d)
+	}
+	if fallback > 0 {
+		return time.Duration(fallback)
+	}
+	return defaultCLITimeout
+}
+
+// duration is a time.Duration written as a string ("90s", "2h") in JSON.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "instassist", configFileName)
+}
+
+// loadConfig reads the JSON config at path. A missing file yields an empty
+// config so the app works without any setup.
+func loadConfig(path string) (config, error) {
+	var cfg config
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("read config %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	cfg.normalize()
+	return cfg, nil
+}
+
+func (c *config) normalize() {
+	if len(c.PromptTemplates) > 0 {
+		templates := make(map[string]string, len(c.PromptTemplates))
+		for name, tmpl := range c.PromptTemplates {
+			templates[strings.ToLower(name)] = tmpl
+		}
+		c.PromptTemplates = templates
+	}
+	if len(c.Timeouts) > 0 {
+		timeouts := make(map[string]duration, len(c.Timeouts))
+		for name, d := range c.Timeouts {
+			timeouts[strings.ToLower(name)] = d
+		}
+		c.Timeouts = timeouts
+	}
+	if len(c.PromptLimits) > 0 {
+		limits := make(map[string]promptLimit, len(c.PromptLimits))
+		for name, lim := range c.PromptLimits {
+			limits[strings.ToLower(name)] = lim
+		}
+		c.PromptLimits = limits
+	}
+	for name, p := range c.Profiles {
+		p.normalize()
+		c.Profiles[name] = p
+	}
+}
+
+// withProfile returns the config with the named profile's settings layered on
+// top. Only fields the profile sets are overridden.
+func (c config) withProfile(name string) (config, error) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		available := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			available = append(available, n)
+		}
+		sort.Strings(available)
+		if len(available) == 0 {
+			return c, fmt.Errorf("profile %q not found: no profiles defined in config", name)
+		}
+		return c, fmt.Errorf("profile %q not found (available: %s)", name, strings.Join(available, ", "))
+	}
+
+	if p.DefaultCLI != "" {
+		c.DefaultCLI = p.DefaultCLI
+	}
+	if len(p.CLIEnv) > 0 {
+		c.CLIEnv = p.CLIEnv
+	}
+	if p.PromptPrefix != "" {
+		c.PromptPrefix = p.PromptPrefix
+	}
+	if p.Style != "" {
+		c.Style = p.Style
+	}
+	if p.Language != "" {
+		c.Language = p.Language
+	}
+	if p.Schema != "" {
+		c.Schema = p.Schema
+	}
+	if p.PreferEmbeddedSchema {
+		c.PreferEmbeddedSchema = true
+	}
+	if p.MaxOrder != 0 {
+		c.MaxOrder = p.MaxOrder
+	}
+	if p.DropUnordered {
+		c.DropUnordered = true
+	}
+	if p.ExecMode != "" {
+		c.ExecMode = p.ExecMode
+	}
+	if p.ExecTemplate != "" {
+		c.ExecTemplate = p.ExecTemplate
+	}
+	if p.ScriptFileBytes != 0 {
+		c.ScriptFileBytes = p.ScriptFileBytes
+	}
+	if p.Append {
+		c.Append = true
+	}
+	if p.StickyPrompt {
+		c.StickyPrompt = true
+	}
+	if p.AppendSeparator != "" {
+		c.AppendSeparator = p.AppendSeparator
+	}
+	if p.CopyNewline {
+		c.CopyNewline = true
+	}
+	if p.Clipboard != "" {
+		c.Clipboard = p.Clipboard
+	}
+	if p.Pipe != "" {
+		c.Pipe = p.Pipe
+	}
+	if p.OnCopy != "" {
+		c.OnCopy = p.OnCopy
+	}
+	if p.ContinueOnError {
+		c.ContinueOnError = true
+	}
+	if p.Postprocess != "" {
+		c.Postprocess = p.Postprocess
+	}
+	if p.ParseMode != "" {
+		c.ParseMode = p.ParseMode
+	}
+	if p.SortBy != "" {
+		c.SortBy = p.SortBy
+	}
+	if p.Raw {
+		c.Raw = true
+	}
+	if p.MaxParseRetries != 0 {
+		c.MaxParseRetries = p.MaxParseRetries
+	}
+	if p.MaxEmptyRetries != 0 {
+		c.MaxEmptyRetries = p.MaxEmptyRetries
+	}
+	if p.Timeout != 0 {
+		c.Timeout = p.Timeout
+	}
+	if len(p.Timeouts) > 0 {
+		timeouts := make(map[string]duration, len(c.Timeouts)+len(p.Timeouts))
+		for k, v := range c.Timeouts {
+			timeouts[k] = v
+		}
+		for k, v := range p.Timeouts {
+			timeouts[k] = v
+		}
+		c.Timeouts = timeouts
+	}
+	if p.PromptWarnChars != 0 {
+		c.PromptWarnChars = p.PromptWarnChars
+	}
+	if p.PromptMaxChars != 0 {
+		c.PromptMaxChars = p.PromptMaxChars
+	}
+	if len(p.PromptLimits) > 0 {
+		limits := make(map[string]promptLimit, len(c.PromptLimits)+len(p.PromptLimits))
+		for k, v := range c.PromptLimits {
+			limits[k] = v
+		}
+		for k, v := range p.PromptLimits {
+			limits[k] = v
+		}
+		c.PromptLimits = limits
+	}
+	if len(p.TTYCLIs) > 0 {
+		c.TTYCLIs = p.TTYCLIs
+	}
+	if p.MaxOutputBytes != 0 {
+		c.MaxOutputBytes = p.MaxOutputBytes
+	}
+	if p.NoCache {
+		c.NoCache = true
+	}
+	if p.CacheTTL != 0 {
+		c.CacheTTL = p.CacheTTL
+	}
+	if len(p.ExecDeny) > 0 {
+		c.ExecDeny = p.ExecDeny
+	}
+	if len(p.ExecAllow) > 0 {
+		c.ExecAllow = p.ExecAllow
+	}
+	if p.Confirm {
+		c.Confirm = true
+	}
+	if p.KeepOpen {
+		c.KeepOpen = true
+	}
+	if p.MaxValueWidth != 0 {
+		c.MaxValueWidth = p.MaxValueWidth
+	}
+	if p.Session {
+		c.Session = true
+	}
+	if p.HideInputHint {
+		c.HideInputHint = true
+	}
+	if p.TickInterval != 0 {
+		c.TickInterval = p.TickInterval
+	}
+	if p.Numbered {
+		c.Numbered = true
+	}
+	if len(p.ExtraFields) > 0 {
+		c.ExtraFields = p.ExtraFields
+	}
+	if p.ShowOrder != nil {
+		c.ShowOrder = p.ShowOrder
+	}
+	if p.Explain {
+		c.Explain = true
+	}
+	if p.AutoSelect {
+		c.AutoSelect = true
+	}
+	if p.Markdown {
+		c.Markdown = true
+	}
+	if p.Present {
+		c.Present = true
+	}
+	if p.NoColor {
+		c.NoColor = true
+	}
+	if p.StoreFullPrompt {
+		c.StoreFullPrompt = true
+	}
+	if len(p.ServeCLIs) > 0 {
+		c.ServeCLIs = p.ServeCLIs
+	}
+	if p.ASCII {
+		c.ASCII = true
+	}
+	if len(p.PromptTemplates) > 0 {
+		templates := make(map[string]string, len(c.PromptTemplates)+len(p.PromptTemplates))
+		for k, v := range c.PromptTemplates {
+			templates[k] = v
+		}
+		for k, v := range p.PromptTemplates {
+			templates[k] = v
+		}
+		c.PromptTemplates = templates
+	}
+	return c, nil
+}
@@ -0,0 +1,149 @@
+package instassist
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigMissingFileIsEmpty(t *testing.T) {
+	cfg, err := loadConfig(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if len(cfg.PromptTemplates) != 0 {
+		t.Fatalf("expected empty config, got %+v", cfg)
+	}
+}
+
+func TestLoadConfigNormalizesTemplateKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"prompt_templates":{"Gemini":"{{prompt}}"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if cfg.PromptTemplates["gemini"] != "{{prompt}}" {
+		t.Fatalf("expected lowercase key, got %+v", cfg.PromptTemplates)
+	}
+}
+
+func TestConfigWithProfile(t *testing.T) {
+	base := config{
+		DefaultCLI:      "claude",
+		PromptTemplates: map[string]string{"codex": "base"},
+		Profiles: map[string]config{
+			"writing": {
+				DefaultCLI:      "codex",
+				PromptPrefix:    "Answer as prose.",
+				PromptTemplates: map[string]string{"claude": "profile"},
+			},
+		},
+	}
+
+	cfg, err := base.withProfile("writing")
+	if err != nil {
+		t.Fatalf("withProfile returned error: %v", err)
+	}
+	if cfg.DefaultCLI != "codex" || cfg.PromptPrefix != "Answer as prose." {
+		t.Fatalf("profile fields not applied: %+v", cfg)
+	}
+	if cfg.PromptTemplates["codex"] != "base" || cfg.PromptTemplates["claude"] != "profile" {
+		t.Fatalf("expected merged templates, got %+v", cfg.PromptTemplates)
+	}
+	if base.PromptTemplates["claude"] != "" {
+		t.Fatalf("base config was mutated: %+v", base.PromptTemplates)
+	}
+
+	if _, err := base.withProfile("missing"); err == nil || !strings.Contains(err.Error(), "writing") {
+		t.Fatalf("expected error listing available profiles, got %v", err)
+	}
+}
+
+func TestLoadConfigParsesDurations(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{"cache_ttl":"90m"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if time.Duration(cfg.CacheTTL) != 90*time.Minute {
+		t.Fatalf("expected 90m, got %v", time.Duration(cfg.CacheTTL))
+	}
+
+	if err := os.WriteFile(path, []byte(`{"cache_ttl":"soon"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(path); err == nil {
+		t.Fatal("expected invalid duration to be rejected")
+	}
+}
+
+func TestCLITimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"timeout":"2m","timeouts":{"Codex":"3m"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig returned error: %v", err)
+	}
+	if got := cliTimeout("codex", cfg.Timeout, cfg.Timeouts); got != 3*time.Minute {
+		t.Fatalf("expected the per-CLI timeout, got %v", got)
+	}
+	if got := cliTimeout("claude", cfg.Timeout, cfg.Timeouts); got != 2*time.Minute {
+		t.Fatalf("expected the global timeout, got %v", got)
+	}
+	if got := cliTimeout("claude", 0, nil); got != defaultCLITimeout {
+		t.Fatalf("expected the default timeout, got %v", got)
+	}
+}
+
+func TestDetectCLI(t *testing.T) {
+	env := map[string]string{"OPENAI_API_KEY": "sk-x", "WORK_KEY": "1"}
+	getenv := func(k string) string { return env[k] }
+
+	if got := detectCLI(nil, getenv); got != "codex" {
+		t.Fatalf("expected codex from OPENAI_API_KEY, got %q", got)
+	}
+	if got := detectCLI([]envCLI{{Env: "WORK_KEY", CLI: "claude"}}, getenv); got != "claude" {
+		t.Fatalf("expected configured rules to win, got %q", got)
+	}
+	if got := detectCLI(nil, func(string) string { return "" }); got != "" {
+		t.Fatalf("expected no match without credentials, got %q", got)
+	}
+}
+
+func TestParseFlagsWithEnv(t *testing.T) {
+	newSet := func() (*flag.FlagSet, *string, *time.Duration) {
+		fs := flag.NewFlagSet("inst", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		return fs, fs.String("cli", "claude", ""), fs.Duration("timeout", time.Minute, "")
+	}
+
+	fs, cli, timeout := newSet()
+	if err := parseFlagsWithEnv(fs, "-cli codex -timeout 2m", []string{"-cli", "gemini"}); err != nil {
+		t.Fatal(err)
+	}
+	if *cli != "gemini" || *timeout != 2*time.Minute {
+		t.Fatalf("got cli=%s timeout=%s, want the command line to win and the env to fill in", *cli, *timeout)
+	}
+
+	fs, _, _ = newSet()
+	if err := parseFlagsWithEnv(fs, "codex", nil); err == nil {
+		t.Fatal("expected an error for a stray argument in the environment")
+	}
+	fs, _, _ = newSet()
+	if err := parseFlagsWithEnv(fs, "-cli 'unterminated", nil); err == nil {
+		t.Fatal("expected an error for bad quoting")
+	}
+}
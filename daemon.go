@@ -0,0 +1,124 @@
+package instassist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Daemon mode keeps the TUI resident; `inst -trigger` connects over a unix
+// socket, sends one line with an optional prompt, and gets back the value
+// the user picked. An empty reply means the user dismissed the request.
+
+// triggerMsg asks a resident TUI to get ready for a new prompt. The picked
+// value (or "" on dismiss) is sent to reply exactly once.
+type triggerMsg struct {
+	prompt string
+	reply  chan<- string
+}
+
+func defaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "instassist.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("instassist-%d.sock", os.Getuid()))
+}
+
+// listenTrigger opens the daemon socket, replacing a stale socket file left
+// by a daemon that didn't shut down cleanly.
+func listenTrigger(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("remove stale socket: %w", err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// serveTriggers forwards each connection to the TUI via send and writes the
+// reply back. It returns when ln is closed.
+func serveTriggers(ln net.Listener, send func(tea.Msg)) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return
+			}
+			reply := make(chan string, 1)
+			send(triggerMsg{prompt: strings.TrimSpace(line), reply: reply})
+			_, _ = io.WriteString(conn, <-reply)
+		}(conn)
+	}
+}
+
+// sendTrigger asks the daemon at path for a value, blocking until the user
+// picks one or dismisses the request.
+func sendTrigger(path, prompt string) (string, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return "", fmt.Errorf("no daemon listening on %s (start one with -daemon): %w", path, err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, strings.ReplaceAll(prompt, "\n", " ")+"\n"); err != nil {
+		return "", err
+	}
+	out, err := io.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// replyTrigger answers the pending trigger, if any.
+func (m *model) replyTrigger(value string) {
+	if m.trigger != nil {
+		m.trigger <- value
+		m.trigger = nil
+	}
+}
+
+func (m model) handleTrigger(msg triggerMsg) (tea.Model, tea.Cmd) {
+	// A newer trigger supersedes one the user never answered, and the
+	// request it may have started.
+	m.replyTrigger("")
+	if m.running {
+		m.abandonRun()
+	}
+	m.resetForNewPrompt()
+	m.input.SetValue(msg.prompt)
+	m.adjustTextareaHeight()
+	m.trigger = msg.reply
+	return m, nil
+}
+
+// dismiss handles esc/q. A daemon goes back to waiting instead of exiting.
+func (m model) dismiss() (tea.Model, tea.Cmd) {
+	if !m.daemon {
+		return m, tea.Quit
+	}
+	m.replyTrigger("")
+	m.resetForNewPrompt()
+	return m, nil
+}
@@ -0,0 +1,154 @@
+package instassist
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestTriggerRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inst.sock")
+	ln, err := listenTrigger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	var got string
+	go serveTriggers(ln, func(msg tea.Msg) {
+		tm := msg.(triggerMsg)
+		got = tm.prompt
+		tm.reply <- "ls -la"
+	})
+
+	value, err := sendTrigger(path, "list files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "ls -la" || got != "list files" {
+		t.Fatalf("expected prompt %q -> %q, got %q -> %q", "list files", "ls -la", got, value)
+	}
+	if _, err := listenTrigger(path); err == nil {
+		t.Fatal("expected a second daemon on the same socket to fail")
+	}
+}
+
+func TestModelCancelRunRepliesToTrigger(t *testing.T) {
+	m := newTestModel(t)
+	m.daemon = true
+	reply := make(chan string, 1)
+	m = update(t, m, triggerMsg{prompt: "list files", reply: reply})
+	m, _ = submit(t, m, "list files")
+	if !m.running {
+		t.Fatal("expected the triggered prompt to be running")
+	}
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = next.(model)
+	if cmd != nil || m.running || m.mode != modeInput {
+		t.Fatalf("expected esc to cancel without quitting, got running=%v mode=%v", m.running, m.mode)
+	}
+	select {
+	case v := <-reply:
+		if v != "" {
+			t.Fatalf("expected an empty reply on cancel, got %q", v)
+		}
+	default:
+		t.Fatal("expected the trigger client to get a reply")
+	}
+	if m.trigger != nil {
+		t.Fatal("expected the trigger to be cleared after replying")
+	}
+}
+
+func TestModelTriggerAbandonsRunningRequest(t *testing.T) {
+	m := newTestModel(t)
+	m.daemon = true
+	m = update(t, m, triggerMsg{prompt: "old prompt", reply: make(chan string, 1)})
+	m, _ = submit(t, m, "old prompt")
+	oldGen := m.runGen
+
+	m = update(t, m, triggerMsg{prompt: "list files", reply: make(chan string, 1)})
+	if m.running || m.runGen == oldGen {
+		t.Fatalf("expected the trigger to abandon the run, got running=%v gen=%d", m.running, m.runGen)
+	}
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude", gen: oldGen})
+	if m.options != nil || !m.running {
+		t.Fatalf("expected the old reply to be dropped, got %d options, running=%v", len(m.options), m.running)
+	}
+}
+
+func TestModelTriggerDropsPendingConfirm(t *testing.T) {
+	m := newTestModel(t)
+	m.daemon = true
+	m.confirm = true
+	m = update(t, m, triggerMsg{prompt: "old prompt", reply: make(chan string, 1)})
+	m, _ = submit(t, m, "old prompt")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlR})
+	if m.pendingRun == nil {
+		t.Fatal("expected the run to wait for confirmation")
+	}
+
+	m = update(t, m, triggerMsg{prompt: "list files", reply: make(chan string, 1)})
+	if m.pendingRun != nil {
+		t.Fatal("expected a new trigger to drop the pending run")
+	}
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	if m.pendingRun != nil || strings.Contains(m.View(), helpConfirm) {
+		t.Fatal("expected the confirm panel not to come back over the new results")
+	}
+}
+
+func TestModelTriggerRepliesWithRunValue(t *testing.T) {
+	m := newTestModel(t)
+	m.daemon = true
+	m.keepOpen = true
+	reply := make(chan string, 1)
+	m = update(t, m, triggerMsg{prompt: "list files", reply: reply})
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlR})
+	select {
+	case v := <-reply:
+		if v != "a" {
+			t.Fatalf("expected the run value as the reply, got %q", v)
+		}
+	default:
+		t.Fatal("expected the trigger client to get a reply when the value ran")
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.trigger != nil || len(reply) != 0 {
+		t.Fatal("expected no second reply on esc after the run")
+	}
+}
+
+func TestModelTriggerAndDismiss(t *testing.T) {
+	m := newTestModel(t)
+	m.daemon = true
+	m, _ = submit(t, m, "old prompt")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+
+	reply := make(chan string, 1)
+	m = update(t, m, triggerMsg{prompt: "list files", reply: reply})
+	if m.mode != modeInput || m.input.Value() != "list files" || m.options != nil {
+		t.Fatalf("expected a fresh input seeded with the prompt, got mode=%v input=%q", m.mode, m.input.Value())
+	}
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = next.(model)
+	if cmd != nil {
+		t.Fatal("expected esc not to quit in daemon mode")
+	}
+	if v := <-reply; v != "" {
+		t.Fatalf("expected an empty reply on dismiss, got %q", v)
+	}
+	if m.trigger != nil {
+		t.Fatal("expected the trigger to be cleared after replying")
+	}
+}
@@ -0,0 +1,115 @@
+package instassist
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// execValuePlaceholder marks where exec_template puts the selected value.
+const execValuePlaceholder = "{{value}}"
+
+// validateExecTemplate accepts an empty template (run values as-is) or one
+// that uses the value placeholder.
+func validateExecTemplate(tmpl string) error {
+	if tmpl != "" && !strings.Contains(tmpl, execValuePlaceholder) {
+		return fmt.Errorf("exec_template %q has no %s placeholder", tmpl, execValuePlaceholder)
+	}
+	return nil
+}
+
+// applyExecTemplate wraps value in tmpl, e.g. "time {{value}}". Each line of
+// a multi-line value is wrapped on its own, so every command gets the
+// template rather than just the first. Values are wrapped one by one before
+// they are chained for the same reason.
+func applyExecTemplate(tmpl, value string) string {
+	if tmpl == "" {
+		return value
+	}
+	lines := strings.Split(value, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = strings.ReplaceAll(tmpl, execValuePlaceholder, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// execPolicy gates running model-suggested values. A value is blocked when it
+// matches a deny pattern, or when allow patterns are set and none match.
+type execPolicy struct {
+	allow []*regexp.Regexp
+	deny  []*regexp.Regexp
+}
+
+func newExecPolicy(allow, deny []string) (execPolicy, error) {
+	var p execPolicy
+	var err error
+	if p.allow, err = compilePatterns("exec_allow", allow); err != nil {
+		return p, err
+	}
+	if p.deny, err = compilePatterns("exec_deny", deny); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+func compilePatterns(key string, patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pat := range patterns {
+		re, err := regexp.Compile(pat)
+		if err != nil {
+			return nil, fmt.Errorf("%s pattern %q: %w", key, pat, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// check returns why value may not run, or nil. Each line of a multi-line
+// value is checked on its own as well, so an anchored allow pattern that
+// matches the first command can't let a later line through.
+func (p execPolicy) check(value string) error {
+	for _, re := range p.deny {
+		if re.MatchString(value) {
+			return fmt.Errorf("matches exec_deny pattern %q", re.String())
+		}
+	}
+	for _, line := range strings.Split(value, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if err := p.checkLine(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p execPolicy) checkLine(line string) error {
+	for _, re := range p.deny {
+		if re.MatchString(line) {
+			return fmt.Errorf("matches exec_deny pattern %q", re.String())
+		}
+	}
+	if len(p.allow) == 0 {
+		return nil
+	}
+	for _, re := range p.allow {
+		if re.MatchString(line) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q matches no exec_allow pattern", strings.TrimSpace(line))
+}
+
+// checkAll checks each of values separately, before they are chained into
+// one script.
+func (p execPolicy) checkAll(values []string) error {
+	for _, value := range values {
+		if err := p.check(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package instassist
+
+import "testing"
+
+func TestExecPolicy(t *testing.T) {
+	p, err := newExecPolicy([]string{`^(git|ls|rm)\b`}, []string{`rm\s+-rf`, `curl .*\|\s*(ba)?sh`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value   string
+		blocked bool
+	}{
+		{value: "git status", blocked: false},
+		{value: "ls -la", blocked: false},
+		{value: "rm -rf ./build", blocked: true},
+		{value: "curl https://x.sh | sh", blocked: true},
+		{value: "make", blocked: true},
+		{value: "ls\nrm -rf ~", blocked: true},
+		{value: "ls\nmake", blocked: true},
+		{value: "git fetch\n\ngit status\n", blocked: false},
+	}
+	for _, tt := range tests {
+		if err := p.check(tt.value); (err != nil) != tt.blocked {
+			t.Errorf("check(%q) = %v, want blocked=%v", tt.value, err, tt.blocked)
+		}
+	}
+
+	if err := p.checkAll([]string{"ls", "make"}); err == nil {
+		t.Error("expected a marked value outside exec_allow to block the chain")
+	}
+	if err := p.checkAll([]string{"ls", "git status"}); err != nil {
+		t.Errorf("expected allowed marked values to pass, got %v", err)
+	}
+
+	open, _ := newExecPolicy(nil, nil)
+	if err := open.check("rm -rf /"); err != nil {
+		t.Fatalf("expected an empty policy to allow everything, got %v", err)
+	}
+	if _, err := newExecPolicy(nil, []string{"("}); err == nil {
+		t.Fatal("expected an invalid pattern to be rejected")
+	}
+}
+
+func TestExecTemplate(t *testing.T) {
+	if err := validateExecTemplate(""); err != nil {
+		t.Fatalf("empty template: %v", err)
+	}
+	if err := validateExecTemplate("sudo"); err == nil {
+		t.Fatal("expected a template without {{value}} to be rejected")
+	}
+	if got := applyExecTemplate("time {{value}}", "make test"); got != "time make test" {
+		t.Fatalf("applyExecTemplate = %q", got)
+	}
+	if got := applyExecTemplate("", "make test"); got != "make test" {
+		t.Fatalf("applyExecTemplate without template = %q", got)
+	}
+	if got := applyExecTemplate("time {{value}}", "make\n\nmake test"); got != "time make\n\ntime make test" {
+		t.Fatalf("applyExecTemplate multi-line = %q", got)
+	}
+}
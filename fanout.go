@@ -0,0 +1,274 @@
+package instassist
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Fan-out (ctrl+x) sends one prompt to every available CLI at once. Bubble Tea serializes
+// Update, so the responses never race, but they arrive in any order and
+// handleResponse must not treat the first one as the whole answer. A fanOut
+// collects them instead:
+//
+//   - pending holds the CLIs still running; the batch is done when it is empty.
+//   - each response is stored under its CLI name, so a late reply never
+//     overwrites another CLI's options, and a reply from a CLI that isn't
+//     pending (a duplicate, or one that arrives after expire) is dropped.
+//   - expire ends the batch early when the partial-timeout fires, marking
+//     the stragglers as timed out so the options that did arrive can be shown.
+//   - merged lists the options in the order the CLIs were asked, not the order
+//     they answered, so the view is stable.
+type fanOut struct {
+	clis    []string // in the order they were asked
+	pending map[string]bool
+	results map[string][]optionEntry
+	errs    map[string]error
+}
+
+// fanOutTimeoutError marks a CLI that hadn't answered when the batch expired.
+type fanOutTimeoutError struct{}
+
+func (fanOutTimeoutError) Error() string { return "no reply before the fan-out timeout" }
+
+func newFanOut(clis []string) *fanOut {
+	f := &fanOut{
+		clis:    clis,
+		pending: make(map[string]bool, len(clis)),
+		results: make(map[string][]optionEntry, len(clis)),
+		errs:    make(map[string]error),
+	}
+	for _, name := range clis {
+		f.pending[name] = true
+	}
+	return f
+}
+
+// add records the options (or error) from cli and reports whether every CLI
+// has now answered. Replies from CLIs that aren't pending are ignored.
+func (f *fanOut) add(cli string, opts []optionEntry, err error) (done bool) {
+	if !f.pending[cli] {
+		return f.done()
+	}
+	delete(f.pending, cli)
+	if err != nil {
+		f.errs[cli] = err
+	} else {
+		f.results[cli] = opts
+	}
+	return f.done()
+}
+
+// expire gives up on the CLIs still running.
+func (f *fanOut) expire() {
+	for cli := range f.pending {
+		f.errs[cli] = fanOutTimeoutError{}
+	}
+	clear(f.pending)
+}
+
+func (f *fanOut) done() bool {
+	return len(f.pending) == 0
+}
+
+// merged returns every CLI's options in the order the CLIs were asked,
+// keeping the first copy of a value several CLIs suggested.
+func (f *fanOut) merged() []optionEntry {
+	var all []optionEntry
+	seen := make(map[string]bool)
+	for _, cli := range f.clis {
+		for _, opt := range f.results[cli] {
+			if seen[opt.Value] {
+				continue
+			}
+			seen[opt.Value] = true
+			all = append(all, opt)
+		}
+	}
+	return all
+}
+
+func (f *fanOut) answered() int {
+	return len(f.clis) - len(f.pending)
+}
+
+// fanOutTimeout is how long a fan-out waits for the slowest CLI before showing
+// the options that did arrive.
+const fanOutTimeout = 45 * time.Second
+
+// fanOutExpiredMsg fires fanOutTimeout after the fan-out dispatched as gen.
+type fanOutExpiredMsg struct{ gen int }
+
+// startFanOut sends the prompt in the input box to every CLI. The replies come
+// back as responseMsgs marked fanOut and are collected by handleFanOutResponse.
+func (m model) startFanOut() (tea.Model, tea.Cmd) {
+	userPrompt := strings.TrimRight(m.input.Value(), "\n")
+	if strings.TrimSpace(userPrompt) == "" {
+		m.status = "prompt is empty • " + helpInput
+		return m, nil
+	}
+	if len(m.cliOptions) < 2 {
+		m.status = fmt.Sprintf("only %s is available; fan-out needs two CLIs • %s", m.currentCLI().name, helpInput)
+		return m, nil
+	}
+	promptContent := applyPromptPrefix(m.promptPrefix, userPrompt)
+	prompts := make([]string, len(m.cliOptions))
+	for i, c := range m.cliOptions {
+		prompts[i] = buildPrompt(c.name, m.style, promptContent, m.promptTemplates, m.language, m.attachments)
+		if _, err := checkPromptLength(c.name, prompts[i], promptLimitFor(c.name, m.promptLimit, m.promptLimits)); err != nil {
+			m.status = fmt.Sprintf("%s %v", icons.fail, err)
+			return m, nil
+		}
+	}
+
+	m.clarifying = ""
+	m.promptHistory = []string{userPrompt}
+	m.lastPrompt = userPrompt
+	m.previousOptions = nil
+	m.autoExecute = false
+	m.lastDispatch = promptContent
+	m.lastDispatchSession = ""
+	m.lastFullPrompt = prompts[m.cliIndex]
+	m.stash = nil
+	m.running = true
+	m.mode = modeRunning
+	m.spinnerFrame = 0
+	m.status = ""
+	m.options = nil
+	m.lastParseError = nil
+	m.lastError = nil
+	m.rawOutput = ""
+	m.execOutput = ""
+	m.selected = 0
+	m.pendingResumeID = ""
+
+	// One parent context, so esc (abandonRun) stops every CLI at once.
+	ctx, cancel := context.WithCancel(context.Background())
+	m.runCancel = cancel
+	gen := m.runGen
+	names := make([]string, len(m.cliOptions))
+	cmds := make([]tea.Cmd, 0, len(m.cliOptions)+2)
+	for i, c := range m.cliOptions {
+		names[i] = c.name
+		cmds = append(cmds, m.fanOutCmd(ctx, c, prompts[i], gen))
+	}
+	m.fanOut = newFanOut(names)
+	cmds = append(cmds, tea.Tick(fanOutTimeout, func(time.Time) tea.Msg {
+		return fanOutExpiredMsg{gen: gen}
+	}), m.startTicking())
+
+	m.resizeComponents()
+	return m, tea.Batch(cmds...)
+}
+
+// fanOutCmd runs prompt through one CLI of a fan-out under its own timeout.
+func (m model) fanOutCmd(parent context.Context, c cliOption, prompt string, gen int) tea.Cmd {
+	timeout := cliTimeout(c.name, m.timeout, m.timeouts)
+	yolo := m.yolo
+	maxOutput := m.maxOutputBytes
+	postprocess := m.postprocess
+	return func() (msg tea.Msg) {
+		// Deferred first so it also marks the response recoverAsResponse builds.
+		defer func() {
+			if resp, ok := msg.(responseMsg); ok {
+				resp.fanOut = true
+				msg = resp
+			}
+		}()
+		defer recoverAsResponse(c.name, gen, &msg)
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+		cmd := c.runPrompt(ctx, prompt, yolo)
+		start := time.Now()
+		out, truncated, err := c.capture(cmd, maxOutput)
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		resp := responseMsg{
+			output:    out,
+			err:       err,
+			cli:       c.name,
+			argv:      cmd.Args,
+			truncated: truncated,
+			elapsed:   time.Since(start),
+			gen:       gen,
+		}
+		if err == nil && postprocess != "" {
+			resp.processed, resp.postprocessErr = postprocessOutput(ctx, postprocess, out)
+		}
+		return resp
+	}
+}
+
+// handleFanOutResponse files one CLI's reply and shows the merged options
+// once every CLI has answered.
+func (m model) handleFanOutResponse(msg responseMsg) (tea.Model, tea.Cmd) {
+	if m.fanOut == nil {
+		return m, nil
+	}
+	m.timings.subprocess += msg.elapsed
+	m.timings.calls++
+	var opts []optionEntry
+	err := msg.err
+	if err == nil {
+		text := strings.ToValidUTF8(string(msg.output), "\uFFFD")
+		if msg.processed != nil {
+			text = strings.ToValidUTF8(string(msg.processed), "\uFFFD")
+		}
+		parseStart := time.Now()
+		opts, err = extractOptions(strings.TrimSpace(text), m.parseMode, m.sortBy)
+		m.timings.parse += time.Since(parseStart)
+	}
+	if !m.fanOut.add(msg.cli, opts, err) {
+		m.status = fmt.Sprintf("%s answered • %d/%d CLIs done", msg.cli, m.fanOut.answered(), len(m.fanOut.clis))
+		return m, nil
+	}
+	return m.showFanOut()
+}
+
+// handleFanOutExpired shows what arrived when the slowest CLIs run past
+// fanOutTimeout.
+func (m model) handleFanOutExpired(msg fanOutExpiredMsg) (tea.Model, tea.Cmd) {
+	if m.fanOut == nil || msg.gen != m.runGen {
+		return m, nil
+	}
+	m.fanOut.expire()
+	return m.showFanOut()
+}
+
+// showFanOut ends the fan-out and lists every CLI's options, naming the CLIs
+// that failed or timed out in the status.
+func (m model) showFanOut() (tea.Model, tea.Cmd) {
+	f := m.fanOut
+	// Stops the stragglers after a timeout and drops anything they still send.
+	m.abandonRun()
+	m.mode = modeViewing
+	m.explanation = ""
+	m.marked = nil
+	m.pendingRun = nil
+	m.blockedRun = ""
+	m.optionBlocks = nil
+	m.reasoning = ""
+	m.showInputHint = false
+
+	var failed []string
+	for _, cli := range f.clis {
+		if err := f.errs[cli]; err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", cli, err))
+		}
+	}
+	m.showOptions(f.merged())
+	m.recordExchange(responseMsg{cli: strings.Join(f.clis, ", ")})
+	switch {
+	case len(m.options) == 0:
+		m.status = fmt.Sprintf("%s no CLI returned options • %s • %s", icons.fail, strings.Join(failed, " • "), helpViewing)
+	case len(failed) > 0:
+		m.status = fmt.Sprintf("%s %d/%d CLIs answered • %s • %s", icons.warn, len(f.clis)-len(failed), len(f.clis), strings.Join(failed, " • "), helpViewing)
+	default:
+		m.status = fmt.Sprintf("all %d CLIs answered • %s", len(f.clis), helpViewing)
+	}
+	return m, nil
+}
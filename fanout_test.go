@@ -0,0 +1,122 @@
+package instassist
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func optionValues(opts []optionEntry) []string {
+	out := make([]string, len(opts))
+	for i, o := range opts {
+		out[i] = o.Value
+	}
+	return out
+}
+
+func TestFanOutOutOfOrderResponses(t *testing.T) {
+	f := newFanOut([]string{"claude", "codex", "gemini"})
+
+	if f.add("gemini", []optionEntry{{Value: "du -sh"}}, nil) {
+		t.Fatal("done after one of three replies")
+	}
+	if f.add("claude", []optionEntry{{Value: "df -h"}, {Value: "du -sh"}}, nil) {
+		t.Fatal("done after two of three replies")
+	}
+	// A duplicate reply must not replace the first one.
+	f.add("gemini", []optionEntry{{Value: "stale"}}, nil)
+	if !f.add("codex", nil, errors.New("exit status 1")) {
+		t.Fatal("not done after every CLI replied")
+	}
+
+	got := optionValues(f.merged())
+	want := []string{"df -h", "du -sh"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("merged = %v, want %v (asked order, duplicates dropped)", got, want)
+	}
+	if f.errs["codex"] == nil {
+		t.Error("codex error not recorded")
+	}
+}
+
+func TestFanOutExpireKeepsPartialResults(t *testing.T) {
+	f := newFanOut([]string{"claude", "codex"})
+	f.add("codex", []optionEntry{{Value: "ls"}}, nil)
+	f.expire()
+
+	if !f.done() {
+		t.Fatal("expected the batch to be done after expire")
+	}
+	var timeout fanOutTimeoutError
+	if !errors.As(f.errs["claude"], &timeout) {
+		t.Fatalf("claude error = %v, want a timeout", f.errs["claude"])
+	}
+	// A reply after the timeout is dropped.
+	f.add("claude", []optionEntry{{Value: "late"}}, nil)
+	if got := optionValues(f.merged()); len(got) != 1 || got[0] != "ls" {
+		t.Fatalf("merged = %v, want only the reply that arrived in time", got)
+	}
+}
+
+func newFanOutTestModel(t *testing.T) model {
+	t.Helper()
+	fake := func(ctx context.Context, prompt string, yolo bool) *exec.Cmd {
+		return exec.CommandContext(ctx, "true")
+	}
+	clis := []cliOption{{name: "claude", runPrompt: fake}, {name: "codex", runPrompt: fake}, {name: "gemini", runPrompt: fake}}
+	m := newModelWithCLIs(clis, "claude", false, false, config{})
+	m = update(t, m, tea.WindowSizeMsg{Width: 100, Height: 40})
+	m.input.SetValue("disk usage")
+	return update(t, m, tea.KeyMsg{Type: tea.KeyCtrlX})
+}
+
+func TestModelFanOutCollectsOutOfOrderReplies(t *testing.T) {
+	m := newFanOutTestModel(t)
+	if !m.running || m.fanOut == nil {
+		t.Fatalf("ctrl+x did not start a fan-out: running=%v", m.running)
+	}
+	gen := m.runGen
+
+	m = update(t, m, responseMsg{output: []byte(`{"options":[{"value":"du -sh"}]}`), cli: "gemini", gen: gen, fanOut: true})
+	if !m.running || len(m.options) != 0 {
+		t.Fatalf("first reply ended the fan-out: running=%v options=%v", m.running, optionValues(m.options))
+	}
+	m = update(t, m, responseMsg{err: errors.New("exit status 1"), cli: "codex", gen: gen, fanOut: true})
+	m = update(t, m, responseMsg{output: []byte(`{"options":[{"value":"df -h"},{"value":"du -sh"}]}`), cli: "claude", gen: gen, fanOut: true})
+
+	if m.running || m.mode != modeViewing || m.fanOut != nil {
+		t.Fatalf("expected viewing after every reply: running=%v mode=%v", m.running, m.mode)
+	}
+	if got := optionValues(m.options); strings.Join(got, ",") != "df -h,du -sh" {
+		t.Fatalf("options = %v, want claude's then gemini's without the duplicate", got)
+	}
+	if !strings.Contains(m.status, "2/3 CLIs answered") || !strings.Contains(m.status, "codex: exit status 1") {
+		t.Errorf("status = %q, want the codex failure named", m.status)
+	}
+}
+
+func TestModelFanOutExpiryShowsPartialReplies(t *testing.T) {
+	m := newFanOutTestModel(t)
+	gen := m.runGen
+	m = update(t, m, responseMsg{output: []byte(`{"options":[{"value":"ls"}]}`), cli: "codex", gen: gen, fanOut: true})
+	m = update(t, m, fanOutExpiredMsg{gen: gen})
+
+	if m.running || m.mode != modeViewing {
+		t.Fatalf("expected viewing after the fan-out timeout: running=%v mode=%v", m.running, m.mode)
+	}
+	if got := optionValues(m.options); len(got) != 1 || got[0] != "ls" {
+		t.Fatalf("options = %v, want the reply that arrived in time", got)
+	}
+	if !strings.Contains(m.status, "claude: no reply before the fan-out timeout") {
+		t.Errorf("status = %q, want the timed-out CLIs named", m.status)
+	}
+	// A straggler's reply is dropped rather than replacing the options.
+	m = update(t, m, responseMsg{output: []byte(`{"options":[{"value":"late"}]}`), cli: "claude", gen: gen, fanOut: true})
+	if got := optionValues(m.options); len(got) != 1 || got[0] != "ls" {
+		t.Fatalf("late reply changed the options to %v", got)
+	}
+}
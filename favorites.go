@@ -0,0 +1,217 @@
+package instassist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const favoritesFileName = "favorites.json"
+
+const (
+	helpNameFavorite = "type a name • enter: save • esc: cancel"
+	helpFavorites    = "↑/↓: choose • enter: load • x: delete • esc: cancel"
+)
+
+// favorite is a named prompt saved with ctrl+s and recalled with ctrl+f.
+// Unlike the prompt history, favorites are curated and persist across runs.
+type favorite struct {
+	Name   string `json:"name"`
+	Prompt string `json:"prompt"`
+}
+
+// favoritesPathFor keeps favorites next to the config file; they live in
+// their own file so saving one never rewrites a hand-edited config.
+func favoritesPathFor(configPath string) string {
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), favoritesFileName)
+}
+
+// loadFavorites reads the favorites file. A missing file is an empty list.
+func loadFavorites(path string) ([]favorite, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read favorites: %w", err)
+	}
+	var favs []favorite
+	if err := json.Unmarshal(data, &favs); err != nil {
+		return nil, fmt.Errorf("parse favorites %s: %w", path, err)
+	}
+	return favs, nil
+}
+
+func saveFavorites(path string, favs []favorite) error {
+	data, err := json.MarshalIndent(favs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("save favorites: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("save favorites: %w", err)
+	}
+	return nil
+}
+
+// upsertFavorite replaces the favorite with the same name (ignoring case) or
+// appends a new one.
+func upsertFavorite(favs []favorite, name, prompt string) []favorite {
+	for i, f := range favs {
+		if strings.EqualFold(f.Name, name) {
+			favs[i] = favorite{Name: name, Prompt: prompt}
+			return favs
+		}
+	}
+	return append(favs, favorite{Name: name, Prompt: prompt})
+}
+
+func (m *model) startNamingFavorite() {
+	if m.favoritesPath == "" {
+		m.status = icons.warn + " no config directory to save favorites in"
+		return
+	}
+	if strings.TrimSpace(m.input.Value()) == "" {
+		m.status = "nothing to save • " + helpInput
+		return
+	}
+	m.favoriteName = ""
+	m.mode = modeNameFavorite
+	m.input.Blur()
+	m.status = helpNameFavorite
+}
+
+func (m *model) openFavorites() {
+	favs, err := loadFavorites(m.favoritesPath)
+	if err != nil {
+		m.status = fmt.Sprintf("%s %v", icons.fail, err)
+		return
+	}
+	if len(favs) == 0 {
+		m.status = "no saved prompts yet • ctrl+s saves the current one"
+		return
+	}
+	m.favorites = favs
+	m.favoriteIndex = 0
+	m.mode = modePickFavorite
+	m.input.Blur()
+	m.status = helpFavorites
+}
+
+func (m *model) closeFavorites(status string) {
+	m.mode = modeInput
+	m.input.Focus()
+	m.status = status
+	m.adjustTextareaHeight()
+}
+
+func (m model) handleNameFavoriteKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.closeFavorites(helpInput)
+	case tea.KeyBackspace:
+		if r := []rune(m.favoriteName); len(r) > 0 {
+			m.favoriteName = string(r[:len(r)-1])
+		}
+	case tea.KeySpace:
+		m.favoriteName += " "
+	case tea.KeyRunes:
+		m.favoriteName += string(msg.Runes)
+	case tea.KeyEnter:
+		name := strings.TrimSpace(m.favoriteName)
+		if name == "" {
+			return m, nil
+		}
+		favs, err := loadFavorites(m.favoritesPath)
+		if err == nil {
+			err = saveFavorites(m.favoritesPath, upsertFavorite(favs, name, m.input.Value()))
+		}
+		if err != nil {
+			m.closeFavorites(fmt.Sprintf("%s %v", icons.fail, err))
+			return m, nil
+		}
+		m.closeFavorites(fmt.Sprintf("%s saved prompt as %q • ctrl+f: recall", icons.ok, name))
+	}
+	return m, nil
+}
+
+func (m model) handleFavoritesKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Type == tea.KeyCtrlC:
+		return m, tea.Quit
+	case msg.String() == "esc" || msg.String() == "q":
+		m.closeFavorites(helpInput)
+	case msg.String() == "up" || msg.String() == "k":
+		m.favoriteIndex = (m.favoriteIndex - 1 + len(m.favorites)) % len(m.favorites)
+	case msg.String() == "down" || msg.String() == "j":
+		m.favoriteIndex = (m.favoriteIndex + 1) % len(m.favorites)
+	case msg.Type == tea.KeyEnter:
+		fav := m.favorites[m.favoriteIndex]
+		m.input.SetValue(fav.Prompt)
+		m.closeFavorites(fmt.Sprintf("loaded %q • %s", fav.Name, helpInput))
+	case msg.String() == "x" || msg.Type == tea.KeyDelete:
+		favs := append(m.favorites[:m.favoriteIndex:m.favoriteIndex], m.favorites[m.favoriteIndex+1:]...)
+		if err := saveFavorites(m.favoritesPath, favs); err != nil {
+			m.status = fmt.Sprintf("%s %v", icons.fail, err)
+			return m, nil
+		}
+		m.favorites = favs
+		if len(favs) == 0 {
+			m.closeFavorites("no saved prompts left • " + helpInput)
+			return m, nil
+		}
+		if m.favoriteIndex >= len(favs) {
+			m.favoriteIndex = len(favs) - 1
+		}
+	}
+	return m, nil
+}
+
+func (m model) renderNameFavorite() string {
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Bold(true)
+	previewStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(grayColor))
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Save prompt as: "))
+	b.WriteString(m.favoriteName + "▌")
+	b.WriteString("\n")
+	b.WriteString(previewStyle.Render(cleanText(m.input.Value())))
+	b.WriteString("\n")
+	return b.String()
+}
+
+func (m model) renderFavorites() string {
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("205")).
+		Padding(0, 1)
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Bold(true)
+	selectedStyle := lipgloss.NewStyle().
+		Background(lipgloss.Color("62")).
+		Foreground(lipgloss.Color("230")).
+		Bold(true)
+	normalStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("15"))
+	previewStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(grayColor))
+
+	rows := []string{titleStyle.Render("Saved prompts")}
+	for i, fav := range m.favorites {
+		line := normalStyle.Render("  " + fav.Name)
+		if i == m.favoriteIndex {
+			line = selectedStyle.Render("▶ " + fav.Name)
+		}
+		rows = append(rows, line+previewStyle.Render("  "+cleanText(fav.Prompt)))
+	}
+	return boxStyle.Render(strings.Join(rows, "\n")) + "\n"
+}
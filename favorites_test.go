@@ -0,0 +1,46 @@
+package instassist
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFavoritesRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "favorites.json")
+
+	favs, err := loadFavorites(path)
+	if err != nil || len(favs) != 0 {
+		t.Fatalf("loadFavorites(missing) = %v, %v; want empty, nil", favs, err)
+	}
+
+	favs = upsertFavorite(favs, "ports", "list open ports")
+	favs = upsertFavorite(favs, "disk", "show disk usage")
+	favs = upsertFavorite(favs, "Ports", "list listening ports")
+	if err := saveFavorites(path, favs); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := loadFavorites(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []favorite{{Name: "Ports", Prompt: "list listening ports"}, {Name: "disk", Prompt: "show disk usage"}}
+	if len(got) != len(want) {
+		t.Fatalf("loaded %d favorites, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("favorite %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFavoritesPathFor(t *testing.T) {
+	if got := favoritesPathFor(""); got != "" {
+		t.Errorf("favoritesPathFor(\"\") = %q, want empty", got)
+	}
+	got := favoritesPathFor(filepath.Join("cfg", "instassist", "config.json"))
+	if want := filepath.Join("cfg", "instassist", "favorites.json"); got != want {
+		t.Errorf("favoritesPathFor = %q, want %q", got, want)
+	}
+}
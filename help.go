@@ -0,0 +1,136 @@
+package instassist
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type keyBinding struct {
+	keys string
+	desc string
+}
+
+type keyGroup struct {
+	title    string
+	bindings []keyBinding
+}
+
+// keymap lists the active bindings for the help overlay. Keep it in sync with
+// the handle*Keys functions.
+var keymap = []keyGroup{
+	{title: "Input", bindings: []keyBinding{
+		{"enter", "send prompt"},
+		{"ctrl+r", "send and run the first option"},
+		{"alt+enter / ctrl+j", "insert newline"},
+		{"ctrl+n / ctrl+p", "next / previous CLI"},
+		{"ctrl+o", "open the CLI picker"},
+		{"ctrl+l", "back to the previously selected CLI"},
+		{"ctrl+x", "send to every CLI and merge their options"},
+		{"ctrl+z", "reopen the previous results (until the next prompt is sent)"},
+		{"ctrl+s", "save the prompt as a named favorite"},
+		{"ctrl+f", "load a saved favorite"},
+		{"ctrl+g", "toggle raw mode"},
+		{"ctrl+y", "toggle yolo"},
+		{"esc / ctrl+c", "quit"},
+	}},
+	{title: "Results", bindings: []keyBinding{
+		{"up/down, j/k", "move selection (scroll in raw mode)"},
+		{"ctrl+d / ctrl+u", "half page down / up"},
+		{"g / G", "first / last option"},
+		{"enter", "copy and exit"},
+		{"y", "copy and stay (appends with -append)"},
+		{"ctrl+r", "run selected (or marked) and exit"},
+		{"space", "mark for a multi-command run"},
+		{"a", "refine in the same session"},
+		{"r", "regenerate"},
+		{"+", "ask for more alternatives"},
+		{"b", "cycle options blocks when the reply had several"},
+		{"w", "show/hide the model's reasoning, when it sent any"},
+		{"e", "explain the selected option"},
+		{"d", "toggle new-option highlight"},
+		{"v", "cycle detail: descriptions / +order / values only"},
+		{"|", "pipe into the -pipe command"},
+		{"c", "open the CLI picker"},
+		{"-", "back to the previously selected CLI"},
+		{"o", "open the selected URL in the browser"},
+		{"p", "copy the prompt"},
+		{"m", "copy all as markdown"},
+		{"t", "copy all as TSV (for spreadsheets)"},
+		{"Y", "copy all values, one per line"},
+		{"D", "copy the selected value with its description"},
+		{"s", "split view: options beside the raw reply (J/K scroll it)"},
+		{"i", "show the last CLI command line"},
+		{"n", "new prompt"},
+		{"ctrl+y", "toggle yolo"},
+		{"esc / q / ctrl+c", "discard and quit (exit status 2)"},
+	}},
+	{title: "Explanation", bindings: []keyBinding{
+		{"j/k", "scroll"},
+		{"enter", "copy the explanation"},
+		{"esc / e / q", "back to the options"},
+	}},
+	{title: "Confirm run (-confirm)", bindings: []keyBinding{
+		{"y / enter", "run the command"},
+		{"n / esc / q", "back to the options"},
+	}},
+	{title: "Refine", bindings: []keyBinding{
+		{"enter", "send follow-up"},
+		{"ctrl+r", "send follow-up and run"},
+		{"esc", "quit"},
+	}},
+	{title: "CLI picker", bindings: []keyBinding{
+		{"up/down, j/k", "move"},
+		{"enter", "select"},
+		{"esc / q", "cancel"},
+	}},
+	{title: "Favorites", bindings: []keyBinding{
+		{"up/down, j/k", "move"},
+		{"enter", "load into the prompt"},
+		{"x / delete", "delete"},
+		{"esc / q", "cancel"},
+	}},
+	{title: "While running", bindings: []keyBinding{
+		{"esc", "cancel and go back to the prompt"},
+		{"tab", "cancel and resend to the next CLI"},
+		{"ctrl+c", "quit"},
+	}},
+	{title: "Anywhere", bindings: []keyBinding{
+		{"? (results) / f1", "toggle this help"},
+	}},
+}
+
+func isHelpKey(msg tea.KeyMsg) bool {
+	return msg.Type == tea.KeyF1
+}
+
+func (m model) renderHelpOverlay() string {
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Bold(true)
+	keyStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	descStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("62")).
+		Padding(0, 1)
+
+	keyWidth := 0
+	for _, g := range keymap {
+		for _, kb := range g.bindings {
+			keyWidth = max(keyWidth, lipgloss.Width(kb.keys))
+		}
+	}
+
+	var sections []string
+	for _, g := range keymap {
+		rows := []string{titleStyle.Render(g.title)}
+		for _, kb := range g.bindings {
+			pad := strings.Repeat(" ", keyWidth-lipgloss.Width(kb.keys))
+			rows = append(rows, "  "+keyStyle.Render(kb.keys)+pad+"  "+descStyle.Render(kb.desc))
+		}
+		sections = append(sections, strings.Join(rows, "\n"))
+	}
+	body := strings.Join(sections, "\n\n")
+	footer := lipgloss.NewStyle().Foreground(lipgloss.Color(grayColor)).Render("press any key to close")
+	return boxStyle.Render(body+"\n\n"+footer) + "\n"
+}
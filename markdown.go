@@ -0,0 +1,54 @@
+package instassist
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Descriptions, explanations and reasoning are often markdown; glamour renders
+// them. A renderer compiles its whole style sheet, so one is kept per width
+// rather than built on every View.
+var (
+	markdownMu        sync.Mutex
+	markdownRenderers = map[markdownKey]*glamour.TermRenderer{}
+)
+
+type markdownKey struct {
+	width int
+	plain bool
+}
+
+// renderMarkdown renders md word-wrapped to width. With plain set (-no-color)
+// glamour's notty style lays it out as plain text, keeping inline markup such
+// as **bold**. md is returned as is if glamour fails.
+func renderMarkdown(md string, width int, plain bool) string {
+	key := markdownKey{width: max(20, width), plain: plain}
+	markdownMu.Lock()
+	defer markdownMu.Unlock()
+	r, ok := markdownRenderers[key]
+	if !ok {
+		style := styles.DarkStyle
+		if plain {
+			style = styles.NoTTYStyle
+		}
+		var err error
+		r, err = glamour.NewTermRenderer(
+			glamour.WithStandardStyle(style),
+			glamour.WithColorProfile(lipgloss.ColorProfile()),
+			glamour.WithWordWrap(key.width),
+		)
+		if err != nil {
+			return md
+		}
+		markdownRenderers[key] = r
+	}
+	out, err := r.Render(md)
+	if err != nil {
+		return md
+	}
+	return strings.Trim(out, "\n")
+}
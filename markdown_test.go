@@ -0,0 +1,33 @@
+package instassist
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdownPlain(t *testing.T) {
+	md := "# Usage\n- lists **all** files\n* shows `-la` flags\n1. first\n```\nls -la\n```"
+	got := renderMarkdown(md, 80, true)
+	for _, want := range []string{"# Usage", "• lists **all** files", "• shows -la flags", "1. first", "  ls -la"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "```") || strings.Contains(got, "\x1b[") {
+		t.Errorf("markup or color left in plain output:\n%s", got)
+	}
+}
+
+func TestModelMarkdownDetailView(t *testing.T) {
+	m := newTestModel(t)
+	m.markdown = true
+	m.noColor = true
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(`{"options":[{"value":"ls -la","description":"lists **all** files"}]}`), cli: "claude"})
+	m = update(t, m, explainMsg{value: "ls -la", output: []byte("- shows hidden files")})
+
+	got := strings.Join(m.scrollLines(), "\n")
+	if !strings.Contains(got, "lists **all** files") || !strings.Contains(got, "• shows hidden files") {
+		t.Fatalf("expected the description and explanation rendered, got:\n%s", got)
+	}
+}
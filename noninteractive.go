@@ -1,87 +1,286 @@
 package instassist
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
+	"runtime"
 	"strings"
-	"time"
-
-	"github.com/atotto/clipboard"
+	"syscall"
 )
 
-func runNonInteractive(cliName, userPrompt string, selectIndex int, outputMode string, yolo bool) {
-	schemaPath, schemaJSON, err := schemaSources()
+// selectRecommended asks pickValue for the recommended option rather than an
+// index; -top uses it.
+const selectRecommended = -2
+
+// pickValue returns the value -select chose: the option at selectIndex, the
+// one with the lowest recommendation_order for selectRecommended, or else
+// the first one listed.
+func pickValue(opts []optionEntry, selectIndex int) string {
+	switch {
+	case selectIndex == selectRecommended:
+		return opts[recommendedIndex(opts)].Value
+	case selectIndex >= 0 && selectIndex < len(opts):
+		return opts[selectIndex].Value
+	}
+	return opts[0].Value
+}
+
+func runNonInteractive(cliName, userPrompt string, attachments []attachment, policy execPolicy, selectIndex int, outputMode string, yolo bool, cfg config) {
+	schema, err := schemaSources(cfg.Schema, cfg.PreferEmbeddedSchema)
+	if err == nil {
+		schema, err = schema.forStyle(cfg.Style)
+	}
 	if err != nil {
-		log.Fatalf("schema not found: %v", err)
+		fatalf("%s", schemaHelp(err))
+	}
+	schema.removeOnTeardown()
+	defer teardown()
+	if schema.warning != "" {
+		log.Printf("warning: %s", schema.warning)
 	}
 
-	fullPrompt := buildPrompt(userPrompt)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	// Cancel the CLI on SIGINT/SIGTERM so we reach teardown instead of dying
+	// with the temp schema still on disk.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts, warnings, err := queryOptions(ctx, cliName, userPrompt, attachments, schema, yolo, cfg)
+	for _, w := range warnings {
+		log.Printf("warning: %s", w)
+	}
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	selectedValue := pickValue(opts, selectIndex)
+
+	switch strings.ToLower(outputMode) {
+	case "stdout":
+		fmt.Println(selectedValue)
+	case "tsv":
+		fmt.Print(formatOptionsTSV(opts))
+	case "exec":
+		if cfg.Style == styleAnswer {
+			fatalf("-style answer results are not run; use -style commands or -output stdout")
+		}
+		if err := policy.check(selectedValue); err != nil {
+			fatalf("refusing to run %q: %v (use -force-exec to override)", selectedValue, err)
+		}
+		selectedValue = applyExecTemplate(cfg.ExecTemplate, selectedValue)
+		cmd := shellCommand(context.Background(), selectedValue)
+		cleanup := func() {}
+		if cfg.ExecMode == execModeDirect {
+			argv, err := splitArgs(selectedValue)
+			if err != nil {
+				fatalf("exec error: %v", err)
+			}
+			cmd = exec.Command(argv[0], argv[1:]...)
+		} else {
+			argv, done, err := scriptArgv(runtime.GOOS, selectedValue, cfg.ScriptFileBytes)
+			if err != nil {
+				fatalf("exec error: %v", err)
+			}
+			if argv != nil {
+				cmd, cleanup = exec.Command(argv[0], argv[1:]...), done
+			}
+		}
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Stdin = os.Stdin
+		err := cmd.Run()
+		cleanup()
+		if err != nil {
+			fatalf("exec error: %v", err)
+		}
+	case "clipboard":
+		if cfg.Append {
+			sep := cfg.AppendSeparator
+			if sep == "" {
+				sep = defaultAppendSeparator
+			}
+			if err := appendValue(withCopyNewline(selectedValue, cfg.CopyNewline), sep); err != nil {
+				fatalf("clipboard error: %v", err)
+			}
+			fmt.Printf("%s Appended to clipboard: %s\n", icons.ok, selectedValue)
+			copyHookOrWarn(cfg.OnCopy, selectedValue)
+			return
+		}
+		already, err := copyValue(withCopyNewline(selectedValue, cfg.CopyNewline))
+		if err != nil {
+			fatalf("clipboard error: %v\nHint: On Linux, install xclip or xsel (e.g., 'sudo pacman -S xclip')", err)
+		}
+		if already {
+			fmt.Printf("%s Already on clipboard: %s\n", icons.ok, selectedValue)
+		} else {
+			fmt.Printf("%s Copied to clipboard: %s\n", icons.ok, selectedValue)
+		}
+		copyHookOrWarn(cfg.OnCopy, selectedValue)
+	default:
+		fatalf("unknown output mode: %s", outputMode)
+	}
+}
+
+func copyHookOrWarn(command, value string) {
+	if err := runCopyHook(command, value); err != nil {
+		fmt.Fprintf(os.Stderr, "%s on_copy failed: %v\n", icons.warn, err)
+	}
+}
+
+// queryOptions runs cliName on userPrompt and returns the parsed options, or
+// in raw mode the whole reply as a single option. Warnings are problems that
+// didn't stop the run, such as truncated output.
+func queryOptions(ctx context.Context, cliName, userPrompt string, attachments []attachment, schema schemaSource, yolo bool, cfg config) ([]optionEntry, []string, error) {
+	fullPrompt := buildPrompt(cliName, cfg.Style, applyPromptPrefix(cfg.PromptPrefix, userPrompt), cfg.PromptTemplates, cfg.Language, attachments)
+	if cfg.Raw {
+		fullPrompt = appendAttachments(applyPromptPrefix(cfg.PromptPrefix, userPrompt), attachments)
+	}
+	var warnings []string
+	sizeWarning, err := checkPromptLength(cliName, fullPrompt, promptLimitFor(cliName, promptLimit{Warn: cfg.PromptWarnChars, Max: cfg.PromptMaxChars}, cfg.PromptLimits))
+	if err != nil {
+		return nil, nil, err
+	}
+	if sizeWarning != "" {
+		warnings = append(warnings, sizeWarning)
+	}
+	ctx, cancel := context.WithTimeout(ctx, cliTimeout(cliName, cfg.Timeout, cfg.Timeouts))
 	defer cancel()
 
-	var output []byte
+	var cmd *exec.Cmd
 	switch strings.ToLower(cliName) {
 	case "codex":
-		args := []string{"exec", "--output-schema", schemaPath, "--skip-git-repo-check", "--json"}
+		args := []string{"exec", "--output-schema", schema.path, "--skip-git-repo-check", "--json"}
+		if cfg.Raw {
+			args = []string{"exec", "--skip-git-repo-check"}
+		}
 		if yolo {
 			args = append(args, "--yolo")
 		}
-		cmd := exec.CommandContext(ctx, "codex", args...)
+		cmd = exec.CommandContext(ctx, "codex", args...)
 		cmd.Stdin = strings.NewReader(fullPrompt)
-		output, err = cmd.CombinedOutput()
 	case "claude":
-		args := []string{"-p", fullPrompt, "--print", "--output-format", "json", "--json-schema", schemaJSON}
+		args := []string{"-p", fullPrompt, "--print", "--output-format", "json", "--json-schema", schema.json}
+		if cfg.Raw {
+			args = []string{"-p", fullPrompt, "--print", "--output-format", "text"}
+		}
 		if yolo {
 			args = append(args, "--dangerously-skip-permissions")
 		}
-		cmd := exec.CommandContext(ctx, "claude", args...)
-		output, err = cmd.CombinedOutput()
+		cmd = exec.CommandContext(ctx, "claude", args...)
 	default:
-		log.Fatalf("unknown CLI: %s (supported: claude, codex)", cliName)
+		return nil, nil, fmt.Errorf("unknown CLI: %s (supported: claude, codex)", cliName)
 	}
 
+	output, truncated, err := runner(cliName, cfg.TTYCLIs)(cmd, cfg.MaxOutputBytes)
+	if truncated {
+		warnings = append(warnings, fmt.Sprintf("CLI output truncated to %d bytes", len(output)))
+	}
 	if err != nil {
-		log.Fatalf("CLI error: %v\nOutput: %s", err, string(output))
+		if ctx.Err() != nil {
+			// Report the timeout or cancellation, not the kill it caused.
+			err = ctx.Err()
+		}
+		if hint := authHint(cliName, string(output)); hint != "" {
+			return nil, warnings, fmt.Errorf("%s (%w)", hint, err)
+		}
+		return nil, warnings, fmt.Errorf("CLI error: %w\nOutput: %s", err, string(output))
+	}
+
+	parseText := string(output)
+	if cfg.Postprocess != "" {
+		processed, ppErr := postprocessOutput(ctx, cfg.Postprocess, output)
+		if ppErr != nil {
+			warnings = append(warnings, fmt.Sprintf("postprocess failed, parsing raw output: %v", ppErr))
+		} else {
+			parseText = string(processed)
+		}
 	}
 
-	opts, parseErr := extractOptions(string(output))
+	if cfg.Raw {
+		// The whole reply is the answer.
+		value := strings.TrimSpace(parseText)
+		if value == "" {
+			return nil, warnings, fmt.Errorf("empty response")
+		}
+		return []optionEntry{{Value: value}}, warnings, nil
+	}
+
+	opts, parseErr := extractOptions(parseText, cfg.ParseMode, cfg.SortBy)
 	if parseErr != nil {
-		log.Fatalf("parse error: %v\nRaw output: %s", parseErr, string(output))
+		if partial := recoverPartialOptions(parseText, cfg.SortBy); len(partial) > 0 {
+			warnings = append(warnings, fmt.Sprintf("output was cut off; recovered %s from partial output", optionCount(len(partial))))
+			opts, parseErr = partial, nil
+		}
+	}
+	if parseErr != nil {
+		return nil, warnings, fmt.Errorf("parse error: %w\nRaw output: %s", parseErr, string(output))
 	}
 
+	opts = filterByMaxOrder(opts, cfg.MaxOrder, cfg.DropUnordered)
+	if cfg.Style == styleAnswer && len(opts) > 1 {
+		opts = opts[:1]
+	}
 	if len(opts) == 0 {
-		log.Fatalf("no options returned")
+		return nil, warnings, fmt.Errorf("no options returned")
 	}
+	return opts, warnings, nil
+}
 
-	var selectedValue string
-	if selectIndex >= 0 && selectIndex < len(opts) {
-		selectedValue = opts[selectIndex].Value
-	} else {
-		selectedValue = opts[0].Value
-	}
+const defaultMaxOutputBytes = 1 << 20
 
-	switch strings.ToLower(outputMode) {
-	case "stdout":
-		fmt.Println(selectedValue)
-	case "exec":
-		cmd := exec.Command("sh", "-c", selectedValue)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Stdin = os.Stdin
-		if err := cmd.Run(); err != nil {
-			log.Fatalf("exec error: %v", err)
+// cappedBuffer keeps the first limit bytes written and silently drops the
+// rest, so a runaway CLI can't exhaust memory.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	remaining := c.limit - c.buf.Len()
+	if len(p) > remaining {
+		c.truncated = true
+		if remaining > 0 {
+			c.buf.Write(p[:remaining])
 		}
-	case "clipboard":
-		if err := clipboard.WriteAll(selectedValue); err != nil {
-			log.Fatalf("clipboard error: %v\nHint: On Linux, install xclip or xsel (e.g., 'sudo pacman -S xclip')", err)
+		return len(p), nil
+	}
+	c.buf.Write(p)
+	return len(p), nil
+}
+
+// runCapped runs cmd like CombinedOutput but keeps at most limit bytes
+// (limit <= 0 uses the default).
+func runCapped(cmd *exec.Cmd, limit int) ([]byte, bool, error) {
+	if limit <= 0 {
+		limit = defaultMaxOutputBytes
+	}
+	out := &cappedBuffer{limit: limit}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	err := cmd.Run()
+	return out.buf.Bytes(), out.truncated, err
+}
+
+// postprocessOutput pipes CLI output through a user-configured shell command
+// (e.g. "jq .result") and returns its stdout.
+func postprocessOutput(ctx context.Context, command string, output []byte) ([]byte, error) {
+	cmd := shellCommand(ctx, command)
+	cmd.Stdin = bytes.NewReader(output)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
 		}
-		fmt.Printf("✅ Copied to clipboard: %s\n", selectedValue)
-	default:
-		log.Fatalf("unknown output mode: %s", outputMode)
+		return nil, err
 	}
+	return out, nil
 }
 
 func cliAvailable(name string) bool {
@@ -0,0 +1,40 @@
+package instassist
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestPickValueRecommendedWithIndexSort(t *testing.T) {
+	opts, err := parseOptions(threeOptions, sortByIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts[0].Value != "b" {
+		t.Fatalf("expected listed order with by-index, got %q first", opts[0].Value)
+	}
+	if got := pickValue(opts, selectRecommended); got != "a" {
+		t.Fatalf("expected -top to pick the recommended option, got %q", got)
+	}
+	if got := pickValue(opts, -1); got != "b" {
+		t.Fatalf("expected no -select to keep the first listed option, got %q", got)
+	}
+	if got := pickValue(opts, 2); got != "c" {
+		t.Fatalf("expected -select 2 to pick c, got %q", got)
+	}
+}
+
+func TestRunCappedTruncatesLargeOutput(t *testing.T) {
+	out, truncated, err := runCapped(exec.Command("sh", "-c", "printf 0123456789; printf err >&2"), 4)
+	if err != nil {
+		t.Fatalf("runCapped returned error: %v", err)
+	}
+	if !truncated || string(out) != "0123" {
+		t.Fatalf("expected truncated %q, got %q (truncated=%v)", "0123", out, truncated)
+	}
+
+	out, truncated, err = runCapped(exec.Command("sh", "-c", "printf ok"), 0)
+	if err != nil || truncated || string(out) != "ok" {
+		t.Fatalf("expected untruncated ok, got %q truncated=%v err=%v", out, truncated, err)
+	}
+}
@@ -2,12 +2,16 @@ package instassist
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -15,58 +19,527 @@ type optionEntry struct {
 	Value               string `json:"value"`
 	Description         string `json:"description"`
 	RecommendationOrder int    `json:"recommendation_order"`
+	// Extra holds any other fields a custom schema adds (tags, risk, ...);
+	// extra_fields picks which are shown.
+	Extra map[string]any `json:"extra,omitempty"`
 }
 
-type optionResponse struct {
-	Options []optionEntry `json:"options"`
+// Schema versions: v1 options had only value and description; v2 added
+// recommendation_order. Responses from either are accepted.
+const currentSchemaVersion = 2
+
+// wireResponse is an options payload as sent, with optional fields kept as
+// pointers so a missing field can be told apart from a zero one.
+type wireResponse struct {
+	Options []wireOption `json:"options"`
+}
+
+type wireOption struct {
+	Value               string         `json:"value"`
+	Description         string         `json:"description"`
+	RecommendationOrder *int           `json:"recommendation_order"`
+	Extra               map[string]any `json:"-"`
 }
 
-func buildPrompt(userPrompt string) string {
-	base := "Give me one or more concise, actionable options with short descriptions for the following. Favor shell commands as the option values whenever the request can be done via the command line; use non-command prose only when a command truly does not apply: "
-	schema := `Respond ONLY with JSON shaped like {"options":[{"value":"...","description":"...","recommendation_order":1}]}. No extra text.`
-	return base + userPrompt + "\n" + schema
+// UnmarshalJSON decodes the schema fields and keeps every other field in
+// Extra.
+func (o *wireOption) UnmarshalJSON(data []byte) error {
+	type plain wireOption
+	if err := json.Unmarshal(data, (*plain)(o)); err != nil {
+		return err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for key := range fields {
+		switch strings.ToLower(key) {
+		case "value", "description", "recommendation_order":
+			delete(fields, key)
+		}
+	}
+	if len(fields) > 0 {
+		o.Extra = fields
+	}
+	return nil
 }
 
-func parseOptions(raw string) ([]optionEntry, error) {
-	var lastOpts []optionEntry
-	search := raw
-	for {
-		idx := strings.Index(search, `{"options"`)
-		if idx < 0 {
+// entries converts the payload, defaulting fields newer than its version. A
+// v1 payload (no recommendation_order anywhere) is ranked in listed order.
+func (w wireResponse) entries() []optionEntry {
+	v1 := true
+	for _, o := range w.Options {
+		if o.RecommendationOrder != nil {
+			v1 = false
 			break
 		}
-		segment := search[idx:]
-		var resp optionResponse
-		decoder := json.NewDecoder(strings.NewReader(segment))
-		if err := decoder.Decode(&resp); err == nil && len(resp.Options) > 0 {
-			opts := resp.Options
-			sort.SliceStable(opts, func(i, j int) bool {
-				oi := opts[i].RecommendationOrder
-				oj := opts[j].RecommendationOrder
-				if oi > 0 && oj > 0 && oi != oj {
-					return oi < oj
-				}
-				if oi > 0 && oj <= 0 {
-					return true
-				}
-				if oi <= 0 && oj > 0 {
-					return false
-				}
-				return i < j
-			})
-			lastOpts = opts
+	}
+	opts := make([]optionEntry, len(w.Options))
+	for i, o := range w.Options {
+		opts[i] = optionEntry{Value: o.Value, Description: o.Description, Extra: o.Extra}
+		switch {
+		case o.RecommendationOrder != nil:
+			opts[i].RecommendationOrder = *o.RecommendationOrder
+		case v1:
+			opts[i].RecommendationOrder = i + 1
+		}
+	}
+	return opts
+}
+
+// validateSchemaJSON reports whether data is a JSON object, the least a
+// schema file must be.
+func validateSchemaJSON(data []byte) error {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("not a valid JSON schema: %w", err)
+	}
+	return nil
+}
+
+// joinWarnings joins the non-empty warnings with "; ".
+func joinWarnings(warnings []string) string {
+	var kept []string
+	for _, w := range warnings {
+		if w != "" {
+			kept = append(kept, w)
+		}
+	}
+	return strings.Join(kept, "; ")
+}
+
+// schemaVersion reports which options schema version a schema document
+// describes, or 0 if it isn't recognizable.
+func schemaVersion(data []byte) int {
+	var doc struct {
+		Properties struct {
+			Options struct {
+				Items struct {
+					Properties map[string]json.RawMessage `json:"properties"`
+				} `json:"items"`
+			} `json:"options"`
+		} `json:"properties"`
+	}
+	if json.Unmarshal(data, &doc) != nil {
+		return 0
+	}
+	props := doc.Properties.Options.Items.Properties
+	switch {
+	case props["value"] == nil:
+		return 0
+	case props["recommendation_order"] == nil:
+		return 1
+	default:
+		return 2
+	}
+}
+
+const promptPlaceholder = "{{prompt}}"
+
+// maxAttachmentBytes caps each -attach file so a stray log or binary doesn't
+// blow the CLI's context.
+const maxAttachmentBytes = 100 << 10
+
+// attachment is a file whose contents are sent along with new prompts.
+type attachment struct {
+	name    string
+	content string
+}
+
+func readAttachment(path string) (attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return attachment{}, fmt.Errorf("attach: %w", err)
+	}
+	if info.IsDir() {
+		return attachment{}, fmt.Errorf("attach %s: is a directory", path)
+	}
+	if info.Size() > maxAttachmentBytes {
+		return attachment{}, fmt.Errorf("attach %s: %d bytes exceeds the %d byte limit", path, info.Size(), maxAttachmentBytes)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return attachment{}, fmt.Errorf("attach: %w", err)
+	}
+	return attachment{name: filepath.Base(path), content: string(data)}, nil
+}
+
+// appendAttachments adds each file after the prompt between BEGIN/END
+// markers so the model can tell the context apart from the request.
+func appendAttachments(prompt string, attachments []attachment) string {
+	if len(attachments) == 0 {
+		return prompt
+	}
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString("\n\nAttached files for context:")
+	for _, a := range attachments {
+		fmt.Fprintf(&b, "\n--- BEGIN FILE %s ---\n%s", a.name, strings.TrimRight(a.content, "\n"))
+		fmt.Fprintf(&b, "\n--- END FILE %s ---", a.name)
+	}
+	return b.String()
+}
+
+// Styles pick the base instruction and how results are used.
+const (
+	styleOptions  = "options"  // concise options, favoring shell commands
+	styleCommands = "commands" // every value is a runnable shell command
+	styleAnswer   = "answer"   // a single best answer; not run
+)
+
+var styleInstructions = map[string]string{
+	styleOptions:  "Give me one or more concise, actionable options with short descriptions for the following. Favor shell commands as the option values whenever the request can be done via the command line; use non-command prose only when a command truly does not apply: ",
+	styleCommands: "Give me one or more shell commands for the following. Every option value must be a complete command that runs as-is in a POSIX shell, with no placeholders or prose; put any explanation in the description: ",
+	styleAnswer:   "Give me the single best answer to the following as exactly one option: the answer itself as the value and a one-sentence justification as the description: ",
+}
+
+func validStyle(style string) bool {
+	_, ok := styleInstructions[style]
+	return ok || style == ""
+}
+
+// buildPrompt wraps the user prompt (and any attachments) with JSON
+// instructions for style. A template configured for cliName replaces the
+// built-in wording. A non-empty lang asks for values and descriptions in
+// that language; JSON keys stay English.
+func buildPrompt(cliName, style, userPrompt string, templates map[string]string, lang string, attachments []attachment) string {
+	userPrompt = appendAttachments(userPrompt, attachments)
+	var prompt string
+	if tmpl := strings.TrimSpace(templates[strings.ToLower(cliName)]); tmpl != "" {
+		if strings.Contains(tmpl, promptPlaceholder) {
+			prompt = strings.ReplaceAll(tmpl, promptPlaceholder, userPrompt)
+		} else {
+			prompt = tmpl + "\n" + userPrompt
+		}
+	} else {
+		base, ok := styleInstructions[style]
+		if !ok {
+			base = styleInstructions[styleOptions]
+		}
+		schema := `Respond ONLY with JSON shaped like {"options":[{"value":"...","description":"...","recommendation_order":1}]}. No extra text.`
+		prompt = base + userPrompt + "\n" + schema
+	}
+	if lang = strings.TrimSpace(lang); lang != "" {
+		prompt += fmt.Sprintf("\nRespond in %s. Keep the JSON keys in English.", lang)
+	}
+	return prompt
+}
+
+// jsonRetryInstruction is appended when re-asking after an unparseable reply.
+const jsonRetryInstruction = "Your previous response was not valid JSON. Respond with only the JSON, no other text."
+
+// emptyRetryInstruction is appended when re-asking after a reply with no
+// options.
+const emptyRetryInstruction = "Your previous response returned zero options. Provide at least one option."
+
+var optionsStartPattern = regexp.MustCompile(`\{\s*"options"\s*:`)
+
+// parseOptions returns the last valid options block in raw, ordered by the
+// sortBy strategy. The last block wins because CLIs that stream reasoning or
+// drafts before the answer put the final answer last.
+func parseOptions(raw, sortBy string) ([]optionEntry, error) {
+	blocks, err := parseOptionBlocks(raw, sortBy)
+	if err != nil {
+		return nil, err
+	}
+	return blocks[len(blocks)-1], nil
+}
+
+// parseOptionBlocks is parseOptions keeping every valid block, in the order
+// they appear, for choosing another one than the last.
+func parseOptionBlocks(raw, sortBy string) ([][]optionEntry, error) {
+	blocks := findOptionBlocks(raw)
+	if len(blocks) == 0 {
+		return nil, parseFailure(raw)
+	}
+	for i, block := range blocks {
+		blocks[i] = orderOptions(block, sortBy)
+	}
+	return blocks, nil
+}
+
+// findOptionBlocks scans raw once and returns every non-empty options object
+// in the order it appears, each in listed order. Candidates nested inside an
+// object that already decoded are skipped, so no byte range is decoded twice
+// on success.
+func findOptionBlocks(raw string) [][]optionEntry {
+	var blocks [][]optionEntry
+	consumed := 0
+	for _, loc := range optionsStartPattern.FindAllStringIndex(raw, -1) {
+		start := loc[0]
+		if start < consumed {
+			continue
+		}
+		var resp wireResponse
+		decoder := json.NewDecoder(strings.NewReader(raw[start:]))
+		if err := decoder.Decode(&resp); err != nil {
+			continue
+		}
+		consumed = start + int(decoder.InputOffset())
+		if len(resp.Options) > 0 {
+			blocks = append(blocks, resp.entries())
+		}
+	}
+	return blocks
+}
+
+// Why a reply yielded no options; test with errors.Is.
+var (
+	// errNoJSON: the reply has no JSON object at all, e.g. a prose answer.
+	errNoJSON = errors.New("no JSON in the reply")
+	// errEmptyOptions: a well-formed options object lists no options.
+	errEmptyOptions = errors.New("the reply lists no options")
+	// errMalformedJSON: there is JSON, but no options object decodes from it.
+	errMalformedJSON = errors.New("malformed or incomplete options JSON")
+)
+
+// parseFailure classifies a reply no options were found in. A CLI envelope
+// around a prose answer counts as no JSON.
+func parseFailure(raw string) error {
+	text := replyText(raw)
+	if text == "" {
+		text = raw
+	}
+	switch {
+	case emptyOptionsReply(raw):
+		return fmt.Errorf("failed to parse options JSON: %w", errEmptyOptions)
+	case !strings.Contains(text, "{"):
+		return fmt.Errorf("failed to parse options JSON: %w", errNoJSON)
+	}
+	return fmt.Errorf("failed to parse options JSON: %w", errMalformedJSON)
+}
+
+// emptyOptionsReply reports whether raw holds a well-formed options object
+// with no options, as opposed to something that isn't options JSON at all.
+func emptyOptionsReply(raw string) bool {
+	candidates := []string{raw, replyText(raw)}
+	if text, ok := unwrapEnvelope(raw); ok {
+		candidates = append(candidates, text)
+	}
+	for _, text := range candidates {
+		for _, loc := range optionsStartPattern.FindAllStringIndex(text, -1) {
+			var resp wireResponse
+			if err := json.NewDecoder(strings.NewReader(text[loc[0]:])).Decode(&resp); err == nil && len(resp.Options) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// recoverPartialOptions salvages the complete option objects from the last
+// options array in raw when the output was cut off mid-stream (for example by
+// a timeout). It returns nil when nothing complete precedes the cut.
+func recoverPartialOptions(raw, sortBy string) []optionEntry {
+	locs := optionsStartPattern.FindAllStringIndex(raw, -1)
+	if len(locs) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(raw[locs[len(locs)-1][1]:]))
+	if tok, err := decoder.Token(); err != nil || tok != json.Delim('[') {
+		return nil
+	}
+	var resp wireResponse
+	for decoder.More() {
+		var opt wireOption
+		if err := decoder.Decode(&opt); err != nil {
+			break
+		}
+		if strings.TrimSpace(opt.Value) != "" {
+			resp.Options = append(resp.Options, opt)
+		}
+	}
+	if len(resp.Options) == 0 {
+		return nil
+	}
+	return orderOptions(resp.entries(), sortBy)
+}
+
+// Sort strategies pick the order options are shown in.
+const (
+	sortByOrder = "by-order" // recommendation_order ascending (default)
+	sortByIndex = "by-index" // as listed in the reply
+)
+
+// optionSorters maps each sort strategy to its comparator. New fields such as
+// a confidence score slot in here.
+var optionSorters = map[string]func(a, b optionEntry) bool{
+	sortByOrder: byRecommendationOrder,
+	sortByIndex: func(a, b optionEntry) bool { return false },
+}
+
+func validSortStrategy(s string) bool {
+	_, ok := optionSorters[s]
+	return s == "" || ok
+}
+
+// orderOptions stably sorts opts by the named strategy; "" means by-order.
+func orderOptions(opts []optionEntry, strategy string) []optionEntry {
+	less, ok := optionSorters[strategy]
+	if !ok {
+		less = byRecommendationOrder
+	}
+	sort.SliceStable(opts, func(i, j int) bool { return less(opts[i], opts[j]) })
+	return opts
+}
+
+// byRecommendationOrder ranks by recommendation_order ascending; options
+// without an order (<= 0) keep their relative position after ranked ones.
+func byRecommendationOrder(a, b optionEntry) bool {
+	oi, oj := a.RecommendationOrder, b.RecommendationOrder
+	if oi > 0 && oj > 0 {
+		return oi < oj
+	}
+	return oi > 0 && oj <= 0
+}
+
+// clarifyingQuestion returns the CLI's reply when it is a question instead of
+// options: no options JSON and ending in "?". Replies wrapped in a CLI
+// envelope (claude's "result", codex's agent_message) are unwrapped first.
+func clarifyingQuestion(raw string) string {
+	text := strings.TrimSpace(replyText(raw))
+	if text == "" || optionsStartPattern.MatchString(text) || !strings.HasSuffix(text, "?") {
+		return ""
+	}
+	return text
+}
+
+func replyText(raw string) string {
+	if text, ok := unwrapEnvelope(raw); ok {
+		return text
+	}
+	var envelope struct {
+		Result *string `json:"result"`
+	}
+	if json.Unmarshal([]byte(strings.TrimSpace(raw)), &envelope) == nil && envelope.Result != nil {
+		return *envelope.Result
+	}
+
+	text, sawJSON := "", false
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	scanner.Buffer(make([]byte, 0, 2*1024*1024), 2*1024*1024)
+	for scanner.Scan() {
+		var event struct {
+			Item struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"item"`
+		}
+		if json.Unmarshal([]byte(scanner.Text()), &event) != nil {
+			continue
+		}
+		sawJSON = true
+		if event.Item.Type == "agent_message" {
+			text = event.Item.Text
+		}
+	}
+	if sawJSON {
+		return text
+	}
+	return raw
+}
+
+// unwrapEnvelope returns the model's text from gemini's --output-format json
+// object ("response") or opencode's run --format json event stream, where the
+// reply arrives as one or more "text" parts. Options JSON inside these is
+// string-escaped, so it has to be unwrapped before scanning.
+func unwrapEnvelope(raw string) (string, bool) {
+	var gemini struct {
+		Response *string `json:"response"`
+	}
+	if json.Unmarshal([]byte(strings.TrimSpace(raw)), &gemini) == nil && gemini.Response != nil {
+		return *gemini.Response, true
+	}
+
+	var text strings.Builder
+	sawText := false
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	scanner.Buffer(make([]byte, 0, 2*1024*1024), 2*1024*1024)
+	for scanner.Scan() {
+		var event struct {
+			Type string `json:"type"`
+			Part struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"part"`
+		}
+		if json.Unmarshal([]byte(scanner.Text()), &event) != nil {
+			continue
+		}
+		if event.Type == "text" && event.Part.Type == "text" {
+			text.WriteString(event.Part.Text)
+			sawText = true
 		}
-		search = search[idx+len(`{"options`):]
 	}
-	if len(lastOpts) > 0 {
-		return lastOpts, nil
+	return text.String(), sawText
+}
+
+// readPromptFile loads a prompt for -prompt-file, dropping the trailing
+// newline editors add.
+func readPromptFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("prompt file %s does not exist", path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("read prompt file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Parse modes select how CLI output is framed.
+const (
+	parseModeAuto   = "auto"   // concatenated objects, falling back to JSON lines
+	parseModeNDJSON = "ndjson" // one JSON value per line; the last with options wins
+)
+
+func validParseMode(mode string) bool {
+	switch mode {
+	case "", parseModeAuto, parseModeNDJSON:
+		return true
+	}
+	return false
+}
+
+// extractOptions finds the options in a CLI reply and orders them by the
+// sortBy strategy.
+func extractOptions(raw, mode, sortBy string) ([]optionEntry, error) {
+	_, opts, err := extractOptionBlocks(raw, mode, sortBy)
+	return opts, err
+}
+
+// extractOptionBlocks is extractOptions returning every options block the
+// reply holds, in order, along with the last one, which is what
+// extractOptions picks. Replies with a single block, and NDJSON streams,
+// give one block.
+func extractOptionBlocks(raw, mode, sortBy string) ([][]optionEntry, []optionEntry, error) {
+	blocks, err := extractListedBlocks(raw, mode)
+	if err != nil {
+		return nil, nil, err
 	}
-	return nil, fmt.Errorf("failed to parse options JSON")
+	for i, block := range blocks {
+		blocks[i] = orderOptions(block, sortBy)
+	}
+	return blocks, blocks[len(blocks)-1], nil
 }
 
-func extractOptions(raw string) ([]optionEntry, error) {
-	if opts, err := parseOptions(raw); err == nil {
-		return opts, nil
+// extractListedBlocks finds the options blocks in raw, each in listed order,
+// scanning the reply once.
+func extractListedBlocks(raw, mode string) ([][]optionEntry, error) {
+	if text, ok := unwrapEnvelope(raw); ok {
+		if blocks := findOptionBlocks(text); len(blocks) > 0 {
+			return blocks, nil
+		}
+	}
+	if mode == parseModeNDJSON {
+		opts, err := extractOptionsNDJSON(raw)
+		if err != nil {
+			return nil, err
+		}
+		return [][]optionEntry{opts}, nil
+	}
+	if blocks := findOptionBlocks(raw); len(blocks) > 0 {
+		return blocks, nil
 	}
 
 	scanner := bufio.NewScanner(strings.NewReader(raw))
@@ -81,11 +554,39 @@ func extractOptions(raw string) ([]optionEntry, error) {
 			continue
 		}
 		if opts := findOptionsInValue(data); len(opts) > 0 {
-			return opts, nil
+			return [][]optionEntry{opts}, nil
 		}
 	}
 
-	return nil, fmt.Errorf("failed to parse options JSON")
+	return nil, parseFailure(raw)
+}
+
+// extractOptionsNDJSON decodes raw line by line and returns the options from
+// the last line that has any. Lines that aren't JSON are skipped.
+func extractOptionsNDJSON(raw string) ([]optionEntry, error) {
+	var last []optionEntry
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	scanner.Buffer(make([]byte, 0, 2*1024*1024), 2*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var data any
+		if err := json.Unmarshal([]byte(line), &data); err != nil {
+			continue
+		}
+		if opts := findOptionsInValue(data); len(opts) > 0 {
+			last = opts
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read JSON lines: %w", err)
+	}
+	if len(last) == 0 {
+		return nil, parseFailure(raw)
+	}
+	return last, nil
 }
 
 func findOptionsInValue(v any) []optionEntry {
@@ -108,7 +609,7 @@ func findOptionsInValue(v any) []optionEntry {
 			}
 		}
 	case string:
-		if opts, err := parseOptions(val); err == nil {
+		if opts, err := parseOptions(val, sortByIndex); err == nil {
 			return opts
 		}
 	}
@@ -121,7 +622,7 @@ func decodeOptionsFromInterface(v any) []optionEntry {
 	if err != nil {
 		return nil
 	}
-	opts, err := parseOptions(string(b))
+	opts, err := parseOptions(string(b), sortByIndex)
 	if err != nil {
 		return nil
 	}
@@ -217,12 +718,163 @@ func pickSessionFromString(s string) string {
 	return ""
 }
 
+func applyPromptPrefix(prefix, userPrompt string) string {
+	if strings.TrimSpace(prefix) == "" {
+		return userPrompt
+	}
+	return strings.TrimRight(prefix, "\n") + "\n" + userPrompt
+}
+
+// filterByMaxOrder keeps options ranked at or above maxOrder. Options with no
+// recommendation_order (<= 0) are kept unless dropUnordered is set.
+func filterByMaxOrder(opts []optionEntry, maxOrder int, dropUnordered bool) []optionEntry {
+	if maxOrder <= 0 {
+		return opts
+	}
+	filtered := make([]optionEntry, 0, len(opts))
+	for _, opt := range opts {
+		if opt.RecommendationOrder <= 0 {
+			if !dropUnordered {
+				filtered = append(filtered, opt)
+			}
+			continue
+		}
+		if opt.RecommendationOrder <= maxOrder {
+			filtered = append(filtered, opt)
+		}
+	}
+	return filtered
+}
+
+func formatOptionsMarkdown(opts []optionEntry) string {
+	var sb strings.Builder
+	for _, opt := range opts {
+		sb.WriteString("- ")
+		sb.WriteString(cleanText(opt.Value))
+		if desc := cleanText(opt.Description); desc != "" {
+			sb.WriteString(" — ")
+			sb.WriteString(desc)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// extraField maps an extra option field to the label it is shown with.
+type extraField struct {
+	Field string `json:"field"`
+	Label string `json:"label"`
+}
+
+// formatExtraFields renders the configured extra fields an option has, e.g.
+// "risk: high • tags: git, vcs". Fields the option lacks are skipped.
+func formatExtraFields(extra map[string]any, fields []extraField) string {
+	var parts []string
+	for _, f := range fields {
+		v, ok := extra[f.Field]
+		if !ok || v == nil {
+			continue
+		}
+		label := f.Label
+		if label == "" {
+			label = f.Field
+		}
+		parts = append(parts, label+": "+formatExtraValue(v))
+	}
+	return strings.Join(parts, " • ")
+}
+
+func formatExtraValue(v any) string {
+	switch val := v.(type) {
+	case string:
+		return cleanText(val)
+	case []any:
+		items := make([]string, len(val))
+		for i, item := range val {
+			items[i] = formatExtraValue(item)
+		}
+		return strings.Join(items, ", ")
+	case map[string]any:
+		b, _ := json.Marshal(val)
+		return string(b)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// formatOptionValues joins every option's value, one per line.
+func formatOptionValues(opts []optionEntry) string {
+	values := make([]string, len(opts))
+	for i, opt := range opts {
+		values[i] = opt.Value
+	}
+	return strings.Join(values, "\n")
+}
+
+// formatOptionWithDescription renders opt as "value — description" for
+// pasting into docs; the description is flattened onto the same line.
+func formatOptionWithDescription(opt optionEntry) string {
+	value := strings.TrimSpace(opt.Value)
+	if desc := cleanText(opt.Description); desc != "" {
+		return value + " — " + desc
+	}
+	return value
+}
+
+// tsvEscaper keeps each option on one TSV row; backslashes are escaped too
+// so the escapes can be undone.
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// formatOptionsTSV renders opts as tab-separated rows of value, description
+// and recommendation order (empty when unset) under a header row.
+func formatOptionsTSV(opts []optionEntry) string {
+	var sb strings.Builder
+	sb.WriteString("value\tdescription\torder\n")
+	for _, opt := range opts {
+		order := ""
+		if opt.RecommendationOrder > 0 {
+			order = strconv.Itoa(opt.RecommendationOrder)
+		}
+		fmt.Fprintf(&sb, "%s\t%s\t%s\n", tsvEscaper.Replace(opt.Value), tsvEscaper.Replace(opt.Description), order)
+	}
+	return sb.String()
+}
+
 func cleanText(s string) string {
 	s = strings.TrimSpace(s)
 	return strings.Join(strings.Fields(strings.ReplaceAll(s, "\n", " ")), " ")
 }
 
-func schemaSources() (string, string, error) {
+type schemaSource struct {
+	path    string
+	json    string
+	warning string // non-fatal problem worth surfacing to the user
+	temp    bool   // path is a temp copy of the embedded schema
+}
+
+func (s schemaSource) removeOnTeardown() {
+	if s.temp {
+		onTeardown(func() { _ = os.Remove(s.path) })
+	}
+}
+
+// schemaSources locates the options schema: an explicit override, then
+// options.schema.json next to the binary, in the working directory, and in
+// the shared schema directory (/usr/local/share/insta-assist, or
+// %ProgramData%\insta-assist on Windows), and finally the embedded copy. With
+// preferEmbedded the on-disk candidates are skipped.
+func schemaSources(override string, preferEmbedded bool) (schemaSource, error) {
+	if override != "" {
+		data, err := os.ReadFile(override)
+		if err != nil {
+			return schemaSource{}, fmt.Errorf("%w: %w", errConfiguredSchema, err)
+		}
+		if err := validateSchemaJSON(data); err != nil {
+			return schemaSource{}, fmt.Errorf("%w %s: %w", errConfiguredSchema, override, err)
+		}
+		return schemaSource{path: override, json: string(data)}, nil
+	}
+
 	tryPaths := []string{}
 
 	if exe, err := os.Executable(); err == nil {
@@ -231,29 +883,158 @@ func schemaSources() (string, string, error) {
 	if cwd, err := os.Getwd(); err == nil {
 		tryPaths = append(tryPaths, filepath.Join(cwd, "options.schema.json"))
 	}
-	tryPaths = append(tryPaths, "/usr/local/share/insta-assist/options.schema.json")
+	tryPaths = append(tryPaths, filepath.Join(defaultSharedSchemaDir(), "options.schema.json"))
 
-	for _, p := range tryPaths {
-		if data, err := os.ReadFile(p); err == nil {
-			return p, string(data), nil
+	// A corrupt file is skipped rather than handed to the CLIs, which fail
+	// on it with unhelpful errors.
+	var skipped []string
+	if !preferEmbedded {
+		for _, p := range tryPaths {
+			if data, err := os.ReadFile(p); err == nil {
+				if err := validateSchemaJSON(data); err != nil {
+					skipped = append(skipped, fmt.Sprintf("skipped %s: %v", p, err))
+					continue
+				}
+				src := schemaSource{path: p, json: string(data)}
+				if v := schemaVersion(data); v > 0 && v < currentSchemaVersion {
+					src.warning = fmt.Sprintf("%s is schema v%d, older than the built-in v%d; responses still parse, but use -prefer-embedded-schema or update the file for the newer fields", p, v, currentSchemaVersion)
+				} else if len(embeddedSchema) > 0 && !sameJSON(data, embeddedSchema) {
+					src.warning = fmt.Sprintf("%s differs from the built-in schema; it may be outdated (use -prefer-embedded-schema to ignore it)", p)
+				}
+				src.warning = joinWarnings(append(skipped, src.warning))
+				return src, nil
+			}
 		}
 	}
 
 	// Fallback to embedded schema if available by writing to a temp file
-	if len(embeddedSchema) > 0 {
-		tmp, err := os.CreateTemp("", "insta-options-schema-*.json")
+	if embeddedSchemaUsable() {
+		path, err := writeTempSchema(embeddedSchema)
 		if err != nil {
-			return "", "", fmt.Errorf("failed to create temp schema file: %w", err)
-		}
-		if _, err := tmp.Write(embeddedSchema); err != nil {
-			tmp.Close()
-			return "", "", fmt.Errorf("failed to write temp schema file: %w", err)
+			return schemaSource{}, err
 		}
-		if err := tmp.Close(); err != nil {
-			return "", "", fmt.Errorf("failed to close temp schema file: %w", err)
+		return schemaSource{path: path, json: string(embeddedSchema), temp: true, warning: joinWarnings(skipped)}, nil
+	}
+
+	return schemaSource{}, fmt.Errorf("%w: options.schema.json not found in executable directory, working directory, or %s, and none is built in", errNoSchema, defaultSharedSchemaDir())
+}
+
+// writeTempSchema writes data to a temp file for CLIs that take the schema
+// as a path, returning the path.
+func writeTempSchema(data []byte) (string, error) {
+	tmp, err := os.CreateTemp("", "insta-options-schema-*.json")
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errTempSchema, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("%w: %w", errTempSchema, err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("%w: %w", errTempSchema, err)
+	}
+	return tmp.Name(), nil
+}
+
+// forStyle returns s with the schema tightened to match style (see
+// styleSchema), written to a temp copy of its own. A temp copy s had is
+// removed, whether or not writing the new one works.
+func (s schemaSource) forStyle(style string) (schemaSource, error) {
+	data, ok := styleSchema([]byte(s.json), style)
+	if !ok {
+		return s, nil
+	}
+	path, err := writeTempSchema(data)
+	if s.temp {
+		_ = os.Remove(s.path)
+	}
+	if err != nil {
+		return schemaSource{}, err
+	}
+	return schemaSource{path: path, json: string(data), warning: s.warning, temp: true}, nil
+}
+
+// styleSchema tightens an options schema for style: commands describes each
+// value as a runnable command, answer allows exactly one option. It reports
+// false, leaving data as is, for the options style and for a schema without
+// the expected options array.
+func styleSchema(data []byte, style string) ([]byte, bool) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return data, false
+	}
+	props, _ := doc["properties"].(map[string]any)
+	options, _ := props["options"].(map[string]any)
+	if options == nil {
+		return data, false
+	}
+	switch style {
+	case styleCommands:
+		items, _ := options["items"].(map[string]any)
+		itemProps, _ := items["properties"].(map[string]any)
+		value, _ := itemProps["value"].(map[string]any)
+		if value == nil {
+			return data, false
 		}
-		return tmp.Name(), string(embeddedSchema), nil
+		value["description"] = "A complete shell command that runs as-is in a POSIX shell, with no placeholders or prose."
+		options["minItems"] = 1
+	case styleAnswer:
+		options["minItems"], options["maxItems"] = 1, 1
+	default:
+		return data, false
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return data, false
+	}
+	return out, true
+}
+
+// Why no options schema could be handed to the CLIs; test with errors.Is.
+var (
+	// errNoSchema: no options schema was found on disk or built in.
+	errNoSchema = errors.New("no options schema")
+	// errConfiguredSchema: the "schema" config setting names a file that
+	// can't be read or isn't JSON.
+	errConfiguredSchema = errors.New("configured schema")
+	// errTempSchema: the temp copy CLIs that take a path need couldn't be
+	// written.
+	errTempSchema = errors.New("failed to write a temp schema file")
+)
+
+// embeddedSchemaUsable reports whether the binary was built with a valid
+// schema; a build from a tree with an empty options.schema.json has none.
+func embeddedSchemaUsable() bool {
+	return len(embeddedSchema) > 0 && validateSchemaJSON(embeddedSchema) == nil
+}
+
+// schemaHelp explains a schemaSources failure and how to fix it, for the
+// fatal startup message.
+func schemaHelp(err error) string {
+	switch {
+	case errors.Is(err, errConfiguredSchema):
+		return fmt.Sprintf("%v\nFix or remove the \"schema\" setting in the config file.", err)
+	case errors.Is(err, errTempSchema):
+		return fmt.Sprintf("%v\nThe schema is passed to codex as a file in %s; make it writable or point TMPDIR at a directory that is.", err, os.TempDir())
+	case !errors.Is(err, errNoSchema):
+		return err.Error()
 	}
+	dir := defaultSharedSchemaDir()
+	return fmt.Sprintf(`%v
+claude and codex are given options.schema.json so they answer with selectable options; instassist can't run without it. To fix:
+  - reinstall with "make install", which copies the schema beside the binary and into %s
+  - or copy options.schema.json from the source tree into %s, next to the binary, or into the working directory
+  - or set "schema" in the config file to the path of a copy
+  - or rebuild from a tree whose options.schema.json is intact, so the schema is built in`, err, dir, dir)
+}
 
-	return "", "", fmt.Errorf("options.schema.json not found in executable directory, working directory, or /usr/local/share/insta-assist")
+// sameJSON compares two JSON documents ignoring formatting differences.
+func sameJSON(a, b []byte) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(bytes.TrimSpace(a), bytes.TrimSpace(b))
+	}
+	return reflect.DeepEqual(va, vb)
 }
@@ -1,13 +1,18 @@
 package instassist
 
 import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
 
 func TestBuildPromptIncludesUserTextAndSchema(t *testing.T) {
 	user := "list files"
-	prompt := buildPrompt(user)
+	prompt := buildPrompt("claude", "", user, nil, "", nil)
 	if !strings.Contains(prompt, user) {
 		t.Fatalf("expected prompt to contain user text %q", user)
 	}
@@ -16,9 +21,80 @@ func TestBuildPromptIncludesUserTextAndSchema(t *testing.T) {
 	}
 }
 
+func TestBuildPromptLanguage(t *testing.T) {
+	prompt := buildPrompt("claude", "", "list files", nil, "German", nil)
+	if !strings.HasSuffix(prompt, "\nRespond in German. Keep the JSON keys in English.") {
+		t.Fatalf("expected a language instruction, got %q", prompt)
+	}
+	templated := buildPrompt("gemini", "", "list files", map[string]string{"gemini": "JSON please: {{prompt}}"}, "French", nil)
+	if templated != "JSON please: list files\nRespond in French. Keep the JSON keys in English." {
+		t.Fatalf("unexpected templated prompt %q", templated)
+	}
+}
+
+func TestBuildPromptStyles(t *testing.T) {
+	if got := buildPrompt("claude", "", "x", nil, "", nil); got != buildPrompt("claude", styleOptions, "x", nil, "", nil) {
+		t.Fatalf("expected the options style by default, got %q", got)
+	}
+	if got := buildPrompt("claude", styleCommands, "x", nil, "", nil); !strings.Contains(got, "runs as-is in a POSIX shell") {
+		t.Fatalf("expected the commands instruction, got %q", got)
+	}
+	if got := buildPrompt("claude", styleAnswer, "x", nil, "", nil); !strings.Contains(got, "exactly one option") {
+		t.Fatalf("expected the answer instruction, got %q", got)
+	}
+}
+
+func TestBuildPromptAttachments(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("port: 80\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := readAttachment(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompt := buildPrompt("claude", "", "fix the port", nil, "", []attachment{a})
+	if !strings.Contains(prompt, "fix the port\n\nAttached files for context:\n--- BEGIN FILE config.yaml ---\nport: 80\n--- END FILE config.yaml ---") {
+		t.Fatalf("expected a delimited attachment, got %q", prompt)
+	}
+
+	big := filepath.Join(dir, "big.log")
+	if err := os.WriteFile(big, make([]byte, maxAttachmentBytes+1), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readAttachment(big); err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Fatalf("expected a size limit error, got %v", err)
+	}
+}
+
+func TestBuildPromptUsesPerCLITemplate(t *testing.T) {
+	templates := map[string]string{
+		"gemini": "Output raw JSON only with an options array. Task: {{prompt}}",
+		"codex":  "Suggest commands.",
+	}
+	tests := []struct {
+		name string
+		cli  string
+		want string
+	}{
+		{name: "placeholder", cli: "gemini", want: "Output raw JSON only with an options array. Task: list files"},
+		{name: "no placeholder appends prompt", cli: "Codex", want: "Suggest commands.\nlist files"},
+		{name: "fallback to default", cli: "claude", want: buildPrompt("claude", "", "list files", nil, "", nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildPrompt(tt.cli, "", "list files", templates, "", nil)
+			if got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
 func TestParseOptionsPrefersLastValidBlock(t *testing.T) {
 	raw := `noise {"options":[{"value":"one","description":"first","recommendation_order":1}]} trailing {"options":[{"value":"two","description":"second","recommendation_order":2}]}`
-	opts, err := parseOptions(raw)
+	opts, err := parseOptions(raw, sortByOrder)
 	if err != nil {
 		t.Fatalf("parseOptions returned error: %v", err)
 	}
@@ -30,9 +106,150 @@ func TestParseOptionsPrefersLastValidBlock(t *testing.T) {
 	}
 }
 
+func TestFindOptionBlocksCollectsAllInOrder(t *testing.T) {
+	raw := `draft: {"options":[{"value":"one","description":"d","recommendation_order":1}]}
+broken: {"options":[{"value":
+final: { "options" : [{"value":"two","description":"d","recommendation_order":1}]}
+empty: {"options":[]}`
+	blocks := findOptionBlocks(raw)
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 blocks, got %d: %+v", len(blocks), blocks)
+	}
+	if blocks[0][0].Value != "one" || blocks[1][0].Value != "two" {
+		t.Fatalf("unexpected blocks: %+v", blocks)
+	}
+}
+
+func TestFindOptionBlocksSkipsNestedCandidates(t *testing.T) {
+	raw := `{"options":[{"value":"outer","description":"d","recommendation_order":1}],"meta":{"options":[{"value":"inner","description":"d","recommendation_order":1}]}}`
+	blocks := findOptionBlocks(raw)
+	if len(blocks) != 1 || blocks[0][0].Value != "outer" {
+		t.Fatalf("expected only the outer block, got %+v", blocks)
+	}
+	opts, err := parseOptions(raw, sortByOrder)
+	if err != nil || opts[0].Value != "outer" {
+		t.Fatalf("expected outer block to win, got %+v (err %v)", opts, err)
+	}
+}
+
+func TestParseOptionsNoValidBlock(t *testing.T) {
+	for _, raw := range []string{"", "plain prose", `{"options":[]}`, `{"options":[{"value":`} {
+		if _, err := parseOptions(raw, sortByOrder); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestParseOptionsSchemaVersions(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []optionEntry
+	}{
+		{
+			name: "v1 without recommendation_order keeps listed order",
+			raw:  `{"options":[{"value":"first","description":"d"},{"value":"second","description":"d","extra":true}]}`,
+			want: []optionEntry{{"first", "d", 1, nil}, {"second", "d", 2, map[string]any{"extra": true}}},
+		},
+		{
+			name: "v2 sorts by recommendation_order",
+			raw:  `{"options":[{"value":"second","description":"d","recommendation_order":2},{"value":"first","description":"d","recommendation_order":1}]}`,
+			want: []optionEntry{{"first", "d", 1, nil}, {"second", "d", 2, nil}},
+		},
+		{
+			name: "partial order leaves the missing ones unranked",
+			raw:  `{"options":[{"value":"loose","description":"d"},{"value":"ranked","description":"d","recommendation_order":1}]}`,
+			want: []optionEntry{{"ranked", "d", 1, nil}, {"loose", "d", 0, nil}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseOptions(tt.raw, sortByOrder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseOptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	v1 := `{"type":"object","properties":{"options":{"type":"array","items":{"type":"object","properties":{"value":{"type":"string"},"description":{"type":"string"}}}}}}`
+	if got := schemaVersion([]byte(v1)); got != 1 {
+		t.Fatalf("v1 schema detected as %d", got)
+	}
+	if got := schemaVersion(embeddedSchema); got != currentSchemaVersion {
+		t.Fatalf("embedded schema detected as %d, want %d", got, currentSchemaVersion)
+	}
+	if got := schemaVersion([]byte(`{"type":"string"}`)); got != 0 {
+		t.Fatalf("unrelated schema detected as %d", got)
+	}
+}
+
+func TestStyleSchema(t *testing.T) {
+	if _, ok := styleSchema(embeddedSchema, styleOptions); ok {
+		t.Fatal("expected the options style to keep the schema as is")
+	}
+	if _, ok := styleSchema([]byte(`{"type":"string"}`), styleAnswer); ok {
+		t.Fatal("expected a schema without an options array to be kept as is")
+	}
+
+	var doc struct {
+		Properties struct {
+			Options struct {
+				MinItems int `json:"minItems"`
+				MaxItems int `json:"maxItems"`
+				Items    struct {
+					Properties struct {
+						Value struct {
+							Description string `json:"description"`
+						} `json:"value"`
+					} `json:"properties"`
+				} `json:"items"`
+			} `json:"options"`
+		} `json:"properties"`
+	}
+	answer, ok := styleSchema(embeddedSchema, styleAnswer)
+	if err := json.Unmarshal(answer, &doc); !ok || err != nil {
+		t.Fatalf("answer schema: ok=%v err=%v", ok, err)
+	}
+	if doc.Properties.Options.MinItems != 1 || doc.Properties.Options.MaxItems != 1 {
+		t.Fatalf("answer schema should allow exactly one option:\n%s", answer)
+	}
+
+	commands, ok := styleSchema(embeddedSchema, styleCommands)
+	if err := json.Unmarshal(commands, &doc); !ok || err != nil {
+		t.Fatalf("commands schema: ok=%v err=%v", ok, err)
+	}
+	if !strings.Contains(doc.Properties.Options.Items.Properties.Value.Description, "shell command") {
+		t.Fatalf("commands schema should describe values as commands:\n%s", commands)
+	}
+	if schemaVersion(commands) != currentSchemaVersion {
+		t.Fatal("expected the commands schema to keep its version")
+	}
+}
+
+func TestSchemaSourceForStyle(t *testing.T) {
+	src := schemaSource{path: "options.schema.json", json: string(embeddedSchema)}
+	if got, err := src.forStyle(styleOptions); err != nil || got != src {
+		t.Fatalf("options style: got %+v, %v", got, err)
+	}
+	got, err := src.forStyle(styleAnswer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(got.path)
+	data, err := os.ReadFile(got.path)
+	if err != nil || string(data) != got.json || !got.temp || !strings.Contains(got.json, "maxItems") {
+		t.Fatalf("answer style: got %+v, file %q (%v)", got, data, err)
+	}
+}
+
 func TestParseOptionsSortsByRecommendationOrder(t *testing.T) {
 	raw := `{"options":[{"value":"late","description":"d","recommendation_order":2},{"value":"early","description":"d","recommendation_order":1},{"value":"unsorted","description":"d","recommendation_order":0}]}`
-	opts, err := parseOptions(raw)
+	opts, err := parseOptions(raw, sortByOrder)
 	if err != nil {
 		t.Fatalf("parseOptions returned error: %v", err)
 	}
@@ -44,6 +261,36 @@ func TestParseOptionsSortsByRecommendationOrder(t *testing.T) {
 	}
 }
 
+func TestExtractOptionsSortByIndexKeepsListedOrder(t *testing.T) {
+	raw := `{"options":[{"value":"late","description":"d","recommendation_order":2},{"value":"early","description":"d","recommendation_order":1}]}`
+	opts, err := extractOptions(raw, parseModeAuto, sortByIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts[0].Value != "late" || opts[1].Value != "early" {
+		t.Fatalf("expected listed order, got %+v", opts)
+	}
+	if !validSortStrategy(sortByIndex) || !validSortStrategy("") || validSortStrategy("by-confidence") {
+		t.Fatal("unexpected sort strategy validation")
+	}
+}
+
+func TestSortOptionsKeepsTiesInInputOrder(t *testing.T) {
+	opts := orderOptions([]optionEntry{
+		{Value: "x", RecommendationOrder: 0},
+		{Value: "a", RecommendationOrder: 1},
+		{Value: "b", RecommendationOrder: 1},
+		{Value: "y", RecommendationOrder: 0},
+	}, sortByOrder)
+	got := []string{opts[0].Value, opts[1].Value, opts[2].Value, opts[3].Value}
+	want := []string{"a", "b", "x", "y"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
 func TestCleanTextCollapsesWhitespace(t *testing.T) {
 	in := "  hello \n world\t\t"
 	got := cleanText(in)
@@ -53,10 +300,141 @@ func TestCleanTextCollapsesWhitespace(t *testing.T) {
 	}
 }
 
+func TestFilterByMaxOrder(t *testing.T) {
+	opts := []optionEntry{
+		{Value: "a", RecommendationOrder: 1},
+		{Value: "b", RecommendationOrder: 3},
+		{Value: "c", RecommendationOrder: 0},
+	}
+	tests := []struct {
+		name          string
+		maxOrder      int
+		dropUnordered bool
+		want          []string
+	}{
+		{name: "disabled", maxOrder: 0, want: []string{"a", "b", "c"}},
+		{name: "keeps unordered", maxOrder: 2, want: []string{"a", "c"}},
+		{name: "drops unordered", maxOrder: 2, dropUnordered: true, want: []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterByMaxOrder(opts, tt.maxOrder, tt.dropUnordered)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %+v", tt.want, got)
+			}
+			for i, v := range tt.want {
+				if got[i].Value != v {
+					t.Fatalf("expected %v, got %+v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatOptionsMarkdown(t *testing.T) {
+	opts := []optionEntry{
+		{Value: "ls -la", Description: "list  all\nfiles"},
+		{Value: "tree"},
+	}
+	got := formatOptionsMarkdown(opts)
+	want := "- ls -la — list all files\n- tree\n"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestFormatOptionsTSV(t *testing.T) {
+	opts := []optionEntry{
+		{Value: "printf 'a\tb'", Description: "tab\nand newline", RecommendationOrder: 1},
+		{Value: `C:\tmp`},
+	}
+	got := formatOptionsTSV(opts)
+	want := "value\tdescription\torder\nprintf 'a\\tb'\ttab\\nand newline\t1\nC:\\\\tmp\t\t\n"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestExtractOptionBlocks(t *testing.T) {
+	raw := `Draft: {"options":[{"value":"draft","description":"d","recommendation_order":1}]}
+Final: {"options":[{"value":"y","description":"","recommendation_order":2},{"value":"x","description":"","recommendation_order":1}]}`
+	blocks, opts, err := extractOptionBlocks(raw, parseModeAuto, sortByOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 2 || blocks[0][0].Value != "draft" || blocks[1][0].Value != "x" {
+		t.Fatalf("unexpected blocks: %+v", blocks)
+	}
+	if len(opts) != 2 || opts[0].Value != "x" {
+		t.Fatalf("options = %+v, want the last block", opts)
+	}
+
+	single, _, err := extractOptionBlocks(`{"options":[{"value":"one","description":""}]}`, parseModeAuto, sortByOrder)
+	if err != nil || len(single) != 1 || single[0][0].Value != "one" {
+		t.Fatalf("single block = %+v, %v", single, err)
+	}
+}
+
+func TestParseOptionsKeepsExtraFields(t *testing.T) {
+	raw := `{"options":[{"value":"rm -rf build","description":"clean","recommendation_order":1,"risk":"high","tags":["fs","cleanup"]}]}`
+	opts, err := parseOptions(raw, sortByOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"risk": "high", "tags": []any{"fs", "cleanup"}}
+	if !reflect.DeepEqual(opts[0].Extra, want) {
+		t.Fatalf("Extra = %#v, want %#v", opts[0].Extra, want)
+	}
+	got := formatExtraFields(opts[0].Extra, []extraField{{Field: "risk", Label: "Risk"}, {Field: "tags"}, {Field: "missing"}})
+	if got != "Risk: high • tags: fs, cleanup" {
+		t.Fatalf("formatExtraFields = %q", got)
+	}
+}
+
+func TestFormatOptionValues(t *testing.T) {
+	opts := []optionEntry{{Value: "git status"}, {Value: "git log\n--oneline"}, {Value: "git diff"}}
+	want := "git status\ngit log\n--oneline\ngit diff"
+	if got := formatOptionValues(opts); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestParseFailureKinds(t *testing.T) {
+	tests := []struct {
+		name, raw string
+		want      error
+	}{
+		{"prose", "sorry, I can't help with that", errNoJSON},
+		{"prose in an envelope", `{"type":"result","result":"sorry, I can't help with that"}`, errNoJSON},
+		{"empty options", `{"options":[]}`, errEmptyOptions},
+		{"empty options in an envelope", `{"type":"result","result":"{\"options\":[]}"}`, errEmptyOptions},
+		{"cut off", `{"options":[{"value":"ls"`, errMalformedJSON},
+		{"wrong shape", `{"answer":"ls"}`, errMalformedJSON},
+	}
+	for _, tt := range tests {
+		_, err := extractOptions(tt.raw, parseModeAuto, sortByOrder)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+	if _, err := extractOptions("plain\ntext", parseModeNDJSON, sortByOrder); !errors.Is(err, errNoJSON) {
+		t.Errorf("ndjson: expected errNoJSON, got %v", err)
+	}
+}
+
+func TestFormatOptionWithDescription(t *testing.T) {
+	if got := formatOptionWithDescription(optionEntry{Value: " du -sh * ", Description: "size of each\nentry"}); got != "du -sh * — size of each entry" {
+		t.Fatalf("unexpected %q", got)
+	}
+	if got := formatOptionWithDescription(optionEntry{Value: "df -h"}); got != "df -h" {
+		t.Fatalf("expected a bare value without a description, got %q", got)
+	}
+}
+
 func TestExtractOptionsFromJSONLines(t *testing.T) {
 	raw := `{"type":"thread.started","thread_id":"019aff05-63c1-76a3-a458-50c0bc1582d2"}
 {"type":"item.completed","item":{"type":"agent_message","text":"{\"options\":[{\"value\":\"one\",\"description\":\"first\",\"recommendation_order\":1}]}"}}`
-	opts, err := extractOptions(raw)
+	opts, err := extractOptions(raw, parseModeAuto, sortByOrder)
 	if err != nil {
 		t.Fatalf("extractOptions returned error: %v", err)
 	}
@@ -65,6 +443,155 @@ func TestExtractOptionsFromJSONLines(t *testing.T) {
 	}
 }
 
+func TestExtractOptionsNDJSONFixtures(t *testing.T) {
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{file: "codex_stream.ndjson", want: []string{"ls -la", "ls"}},
+		{file: "noisy_stream.ndjson", want: []string{"git status"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts, err := extractOptions(string(raw), parseModeNDJSON, sortByOrder)
+			if err != nil {
+				t.Fatalf("extractOptions returned error: %v", err)
+			}
+			var got []string
+			for _, o := range opts {
+				got = append(got, o.Value)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExtractOptionsEnvelopeFixtures(t *testing.T) {
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{file: "gemini_envelope.json", want: []string{"du -sh *", "df -h"}},
+		{file: "opencode_stream.ndjson", want: []string{"kubectl get pods -A", "kubectl get pods"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, mode := range []string{parseModeAuto, parseModeNDJSON} {
+				opts, err := extractOptions(string(raw), mode, sortByOrder)
+				if err != nil {
+					t.Fatalf("%s: extractOptions returned error: %v", mode, err)
+				}
+				var got []string
+				for _, o := range opts {
+					got = append(got, o.Value)
+				}
+				if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+					t.Fatalf("%s: expected %v, got %v", mode, tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRecoverPartialOptions(t *testing.T) {
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{file: "truncated_options.json", want: []string{"tar -czf backup.tgz src", "rsync -a src/ backup/"}},
+		{file: "truncated_after_text.txt", want: []string{"git stash"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := extractOptions(string(raw), parseModeAuto, sortByOrder); err == nil {
+				t.Fatal("expected the truncated fixture not to parse normally")
+			}
+			var got []string
+			for _, o := range recoverPartialOptions(string(raw), sortByOrder) {
+				got = append(got, o.Value)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if opts := recoverPartialOptions(`{"options":[{"value":"l`, sortByOrder); opts != nil {
+		t.Fatalf("expected nothing from a cut inside the first option, got %v", opts)
+	}
+}
+
+func TestExtractOptionsNDJSONNoOptions(t *testing.T) {
+	raw := "{\"type\":\"turn.started\"}\nnot json\n"
+	if _, err := extractOptions(raw, parseModeNDJSON, sortByOrder); err == nil {
+		t.Fatal("expected an error when no line has options")
+	}
+}
+
+func TestClarifyingQuestion(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{name: "plain prose", raw: "Which directory should I search?\n", want: "Which directory should I search?"},
+		{name: "claude envelope", raw: `{"type":"result","result":"Do you mean GNU or BSD sed?","session_id":"x"}`, want: "Do you mean GNU or BSD sed?"},
+		{
+			name: "codex events",
+			raw: `{"type":"thread.started","thread_id":"t"}
+{"type":"item.completed","item":{"type":"agent_message","text":"Which branch?"}}`,
+			want: "Which branch?",
+		},
+		{name: "gemini envelope", raw: `{"response":"Which namespace?","stats":{}}`, want: "Which namespace?"},
+		{
+			name: "opencode events",
+			raw: `{"type":"step_start","part":{"type":"step-start"}}
+{"type":"text","part":{"type":"text","text":"Which "}}
+{"type":"text","part":{"type":"text","text":"cluster?"}}`,
+			want: "Which cluster?",
+		},
+		{name: "statement", raw: "I cannot help with that.", want: ""},
+		{name: "options json", raw: `{"options":[]} anything?`, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clarifyingQuestion(tt.raw); got != tt.want {
+				t.Fatalf("clarifyingQuestion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadPromptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prompt.txt")
+	if err := os.WriteFile(path, []byte("find large files\nin /var\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readPromptFile(path)
+	if err != nil || got != "find large files\nin /var" {
+		t.Fatalf("readPromptFile = %q, %v", got, err)
+	}
+
+	_, err = readPromptFile(filepath.Join(t.TempDir(), "missing.txt"))
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected a clear missing-file error, got %v", err)
+	}
+}
+
 func TestExtractSessionID(t *testing.T) {
 	tests := []struct {
 		name string
@@ -97,3 +624,100 @@ func TestExtractSessionID(t *testing.T) {
 		})
 	}
 }
+
+func TestSchemaSourcesWarnsOnStaleLocalSchema(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	stale := `{"type":"object","properties":{"options":{"type":"array"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "options.schema.json"), []byte(stale), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := schemaSources("", false)
+	if err != nil {
+		t.Fatalf("schemaSources returned error: %v", err)
+	}
+	if src.json != stale || src.warning == "" {
+		t.Fatalf("expected stale local schema with a warning, got %+v", src)
+	}
+
+	src, err = schemaSources("", true)
+	if err != nil {
+		t.Fatalf("schemaSources returned error: %v", err)
+	}
+	defer os.Remove(src.path)
+	if src.json != string(embeddedSchema) || src.warning != "" {
+		t.Fatalf("expected embedded schema without warning, got %+v", src)
+	}
+}
+
+func TestSchemaSourcesSkipsInvalidJSON(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	path := filepath.Join(dir, "options.schema.json")
+	if err := os.WriteFile(path, []byte(`{"type":"object",`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := schemaSources("", false)
+	if err != nil {
+		t.Fatalf("schemaSources returned error: %v", err)
+	}
+	defer os.Remove(src.path)
+	if src.json != string(embeddedSchema) || !strings.Contains(src.warning, "skipped "+path) {
+		t.Fatalf("expected the embedded schema and a skip warning, got path=%s warning=%q", src.path, src.warning)
+	}
+
+	if _, err := schemaSources(path, false); err == nil {
+		t.Fatal("expected an invalid configured schema to be an error")
+	}
+}
+
+func TestSchemaSourcesWithoutAnySchema(t *testing.T) {
+	defer func(saved []byte) { embeddedSchema = saved }(embeddedSchema)
+	embeddedSchema = nil
+
+	_, err := schemaSources("", true)
+	if !errors.Is(err, errNoSchema) {
+		t.Fatalf("expected errNoSchema, got %v", err)
+	}
+	help := schemaHelp(err)
+	for _, want := range []string{"make install", defaultSharedSchemaDir(), `"schema"`} {
+		if !strings.Contains(help, want) {
+			t.Errorf("guidance missing %q:\n%s", want, help)
+		}
+	}
+	_, err = schemaSources(filepath.Join(t.TempDir(), "missing.json"), false)
+	if help := schemaHelp(err); !errors.Is(err, errConfiguredSchema) || !strings.Contains(help, `remove the "schema" setting`) {
+		t.Fatalf("unexpected guidance for a configured schema: %s", help)
+	}
+}
+
+func TestSchemaHelpForUnwritableTempDir(t *testing.T) {
+	t.Setenv("TMPDIR", filepath.Join(t.TempDir(), "missing"))
+	_, err := schemaSources("", true)
+	if !errors.Is(err, errTempSchema) {
+		t.Fatalf("expected errTempSchema, got %v", err)
+	}
+	help := schemaHelp(err)
+	if !strings.Contains(help, "TMPDIR") || strings.Contains(help, `"schema" setting`) {
+		t.Fatalf("unexpected guidance for an unwritable temp dir: %s", help)
+	}
+}
+
+func TestSchemaSourcesMatchingLocalSchemaHasNoWarning(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	// Reformatted but equivalent JSON must not trigger the warning.
+	reformatted := strings.Join(strings.Fields(string(embeddedSchema)), "")
+	if err := os.WriteFile(filepath.Join(dir, "options.schema.json"), []byte(reformatted), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := schemaSources("", false)
+	if err != nil {
+		t.Fatalf("schemaSources returned error: %v", err)
+	}
+	if src.warning != "" {
+		t.Fatalf("expected no warning, got %q", src.warning)
+	}
+}
@@ -0,0 +1,54 @@
+package instassist
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultPromptWarnChars is where the size warning starts when none is
+// configured; there is no hard limit by default.
+const defaultPromptWarnChars = 100_000
+
+// promptLimit bounds the assembled prompt in characters: above Warn the run
+// goes ahead with a warning, above Max it is refused. Zero means unset.
+type promptLimit struct {
+	Warn int `json:"warn"`
+	Max  int `json:"max"`
+}
+
+// promptLimitFor layers the limits configured for cliName over the global
+// ones.
+func promptLimitFor(cliName string, global promptLimit, perCLI map[string]promptLimit) promptLimit {
+	lim := global
+	if p, ok := perCLI[strings.ToLower(cliName)]; ok {
+		if p.Warn != 0 {
+			lim.Warn = p.Warn
+		}
+		if p.Max != 0 {
+			lim.Max = p.Max
+		}
+	}
+	if lim.Warn == 0 {
+		lim.Warn = defaultPromptWarnChars
+	}
+	return lim
+}
+
+// promptSize describes n characters with a rough token estimate (about four
+// characters per token).
+func promptSize(n int) string {
+	return fmt.Sprintf("%d chars, ~%d tokens", n, (n+3)/4)
+}
+
+// checkPromptLength returns a warning when prompt is over lim.Warn, and an
+// error when it is over lim.Max.
+func checkPromptLength(cliName, prompt string, lim promptLimit) (string, error) {
+	n := len([]rune(prompt))
+	if lim.Max > 0 && n > lim.Max {
+		return "", fmt.Errorf("prompt is too long for %s (%s, limit %d chars)", cliName, promptSize(n), lim.Max)
+	}
+	if lim.Warn > 0 && n > lim.Warn {
+		return fmt.Sprintf("large prompt (%s) may exceed %s's context", promptSize(n), cliName), nil
+	}
+	return "", nil
+}
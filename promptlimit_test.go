@@ -0,0 +1,31 @@
+package instassist
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPromptLimitFor(t *testing.T) {
+	global := promptLimit{Max: 500}
+	perCLI := map[string]promptLimit{"ollama": {Warn: 80}}
+	if got := promptLimitFor("claude", global, perCLI); got != (promptLimit{Warn: defaultPromptWarnChars, Max: 500}) {
+		t.Fatalf("claude limit = %+v", got)
+	}
+	if got := promptLimitFor("Ollama", global, perCLI); got != (promptLimit{Warn: 80, Max: 500}) {
+		t.Fatalf("ollama limit = %+v", got)
+	}
+}
+
+func TestCheckPromptLength(t *testing.T) {
+	lim := promptLimit{Warn: 10, Max: 20}
+	if warn, err := checkPromptLength("claude", "short", lim); warn != "" || err != nil {
+		t.Fatalf("short prompt: warn=%q err=%v", warn, err)
+	}
+	warn, err := checkPromptLength("claude", strings.Repeat("x", 16), lim)
+	if err != nil || !strings.Contains(warn, "16 chars, ~4 tokens") {
+		t.Fatalf("warn prompt: warn=%q err=%v", warn, err)
+	}
+	if _, err := checkPromptLength("claude", strings.Repeat("é", 21), lim); err == nil || !strings.Contains(err.Error(), "limit 20") {
+		t.Fatalf("long prompt err = %v, want limit error", err)
+	}
+}
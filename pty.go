@@ -0,0 +1,78 @@
+package instassist
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+// usesTTY reports whether cliName is listed in tty_clis, meaning it must be
+// run on a pseudo-terminal.
+func usesTTY(cliName string, ttyCLIs []string) bool {
+	for _, name := range ttyCLIs {
+		if strings.EqualFold(name, cliName) {
+			return true
+		}
+	}
+	return false
+}
+
+// runner returns how to run cliName: on a pseudo-terminal when it is listed in
+// tty_clis, with plain pipes otherwise.
+func runner(cliName string, ttyCLIs []string) func(*exec.Cmd, int) ([]byte, bool, error) {
+	if usesTTY(cliName, ttyCLIs) {
+		return runCappedTTY
+	}
+	return runCapped
+}
+
+// runCappedTTY is runCapped with the CLI's output, and its input unless the
+// prompt is piped in, on a pseudo-terminal, for CLIs that hang or behave
+// differently without one.
+func runCappedTTY(cmd *exec.Cmd, limit int) ([]byte, bool, error) {
+	if limit <= 0 {
+		limit = defaultMaxOutputBytes
+	}
+	setControllingTTY(cmd)
+	ptm, err := pty.StartWithAttrs(cmd, nil, cmd.SysProcAttr)
+	if errors.Is(err, pty.ErrUnsupported) {
+		return nil, false, fmt.Errorf("tty_clis: running a CLI on a pty is not supported on %s", runtime.GOOS)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer ptm.Close()
+
+	out := &cappedBuffer{limit: limit}
+	copied := make(chan struct{})
+	go func() {
+		// Reads fail with EIO once every copy of the terminal side is closed.
+		_, _ = io.Copy(out, ptm)
+		close(copied)
+	}()
+	err = cmd.Wait()
+	// A leftover background process may hold the pty open; stop reading soon.
+	if dlErr := ptm.SetReadDeadline(time.Now().Add(time.Second)); dlErr != nil && !errors.Is(dlErr, os.ErrNoDeadline) {
+		ptm.Close()
+	}
+	<-copied
+	return cleanTTYOutput(out.buf.Bytes()), out.truncated, err
+}
+
+// ttyEscapePattern matches the CSI (colors, cursor movement) and OSC (window
+// title, hyperlink) escape sequences a CLI writes to a terminal.
+var ttyEscapePattern = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])`)
+
+// cleanTTYOutput undoes what a terminal adds to output: escape sequences for
+// colors and cursor movement, and CRLF line endings.
+func cleanTTYOutput(out []byte) []byte {
+	return []byte(strings.ReplaceAll(ttyEscapePattern.ReplaceAllString(string(out), ""), "\r\n", "\n"))
+}
@@ -0,0 +1,40 @@
+package instassist
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestUsesTTY(t *testing.T) {
+	if !usesTTY("Gemini", []string{"gemini"}) {
+		t.Fatal("expected tty_clis to match case-insensitively")
+	}
+	if usesTTY("claude", []string{"gemini"}) || usesTTY("claude", nil) {
+		t.Fatal("expected unlisted CLIs to use pipes")
+	}
+}
+
+func TestCleanTTYOutput(t *testing.T) {
+	got := string(cleanTTYOutput([]byte("\x1b]0;gemini\x07\x1b[32m{\"options\":[]}\x1b[0m\r\ndone\r\n")))
+	if want := "{\"options\":[]}\ndone\n"; got != want {
+		t.Fatalf("cleanTTYOutput = %q, want %q", got, want)
+	}
+}
+
+func TestRunCappedTTY(t *testing.T) {
+	out, truncated, err := runCappedTTY(exec.Command("sh", "-c", `if [ -t 1 ]; then echo tty; else echo pipe; fi`), 0)
+	if err != nil {
+		t.Skipf("no pty: %v", err)
+	}
+	if truncated || string(out) != "tty\n" {
+		t.Fatalf("output = %q (truncated %v), want the CLI to see a terminal", out, truncated)
+	}
+
+	cmd := exec.Command("sh", "-c", `read -r prompt; if [ -t 1 ]; then echo "$prompt on tty"; fi`)
+	cmd.Stdin = strings.NewReader("list files\n")
+	out, _, err = runCappedTTY(cmd, 0)
+	if err != nil || string(out) != "list files on tty\n" {
+		t.Fatalf("piped prompt: output = %q, err %v", out, err)
+	}
+}
@@ -0,0 +1,99 @@
+package instassist
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxReasoningLines caps the reasoning panel so the options stay in view.
+const maxReasoningLines = 12
+
+// extractReasoning collects the reasoning a CLI reported with its answer:
+// codex's reasoning items in its JSON event stream, and "reasoning" or
+// "thinking" fields in a JSON envelope or in the options payload itself.
+func extractReasoning(raw string) string {
+	var found []string
+	seen := map[string]bool{}
+	add := func(s string) {
+		s = strings.TrimSpace(s)
+		if s != "" && !seen[s] {
+			seen[s] = true
+			found = append(found, s)
+		}
+	}
+	var walk func(v any, depth int)
+	walk = func(v any, depth int) {
+		switch val := v.(type) {
+		case map[string]any:
+			if val["type"] == "reasoning" {
+				if text, ok := val["text"].(string); ok {
+					add(text)
+				}
+			}
+			for key, nested := range val {
+				if text, ok := nested.(string); ok && (key == "reasoning" || key == "thinking") {
+					add(text)
+					continue
+				}
+				walk(nested, depth)
+			}
+		case []any:
+			for _, item := range val {
+				walk(item, depth)
+			}
+		case string:
+			// Envelopes carry the model's JSON answer as a string.
+			if depth < 2 && (strings.Contains(val, `"reasoning"`) || strings.Contains(val, `"thinking"`)) {
+				var inner any
+				if json.Unmarshal([]byte(val), &inner) == nil {
+					walk(inner, depth+1)
+				}
+			}
+		}
+	}
+
+	var whole any
+	if json.Unmarshal([]byte(raw), &whole) == nil {
+		walk(whole, 0)
+		return strings.Join(found, "\n\n")
+	}
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	scanner.Buffer(make([]byte, 0, 2*1024*1024), 2*1024*1024)
+	for scanner.Scan() {
+		var line any
+		if json.Unmarshal([]byte(scanner.Text()), &line) == nil {
+			walk(line, 0)
+		}
+	}
+	return strings.Join(found, "\n\n")
+}
+
+// toggleReasoning shows or hides the reasoning panel ("w").
+func (m *model) toggleReasoning() {
+	if m.reasoning == "" {
+		m.status = "this reply has no reasoning • " + helpViewing
+		return
+	}
+	m.showReasoning = !m.showReasoning
+	if m.showReasoning {
+		m.status = "showing the model's reasoning • w: hide"
+	} else {
+		m.status = helpViewing
+	}
+}
+
+// renderReasoningPanel shows the reply's reasoning under the options.
+func (m model) renderReasoningPanel() string {
+	if !m.showReasoning || m.reasoning == "" {
+		return ""
+	}
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(grayColor)).Bold(true)
+	body := strings.Split(strings.TrimRight(renderMarkdown(m.reasoning, m.width-4, m.noColor), "\n"), "\n")
+	if len(body) > maxReasoningLines {
+		body = append(body[:maxReasoningLines], titleStyle.Render("…"))
+	}
+	return titleStyle.Render("Reasoning") + "\n" + strings.Join(body, "\n") + "\n"
+}
@@ -0,0 +1,47 @@
+package instassist
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestExtractReasoning(t *testing.T) {
+	codex := `{"type":"thread.started","thread_id":"t1"}
+{"type":"item.completed","item":{"id":"i0","type":"reasoning","text":"**Listing** files is safest"}}
+{"type":"item.completed","item":{"id":"i1","type":"agent_message","text":"{\"options\":[{\"value\":\"ls\",\"description\":\"\"}]}"}}`
+	if got := extractReasoning(codex); got != "**Listing** files is safest" {
+		t.Fatalf("codex reasoning = %q", got)
+	}
+
+	claude := `{"type":"result","result":"{\"reasoning\":\"ls is read-only\",\"options\":[{\"value\":\"ls\",\"description\":\"\"}]}"}`
+	if got := extractReasoning(claude); got != "ls is read-only" {
+		t.Fatalf("envelope reasoning = %q", got)
+	}
+
+	if got := extractReasoning(threeOptions); got != "" {
+		t.Fatalf("expected no reasoning, got %q", got)
+	}
+}
+
+func TestModelReasoningPanel(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'w'}})
+	if m.showReasoning || !strings.Contains(m.status, "no reasoning") {
+		t.Fatalf("expected w to report missing reasoning, got %q", m.status)
+	}
+
+	reply := `{"reasoning":"a is the safest","options":[{"value":"a","description":""}]}`
+	m, _ = submit(t, update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}}), "list files")
+	m = update(t, m, responseMsg{output: []byte(reply), cli: "claude"})
+	if strings.Contains(m.View(), "a is the safest") {
+		t.Fatal("expected the reasoning hidden until toggled")
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'w'}})
+	if view := m.View(); !strings.Contains(view, "Reasoning") || !strings.Contains(view, "a is the safest") {
+		t.Fatalf("expected the reasoning panel:\n%s", view)
+	}
+}
@@ -0,0 +1,132 @@
+package instassist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Serve mode answers POST /prompt with the parsed options as JSON, so editor
+// plugins can use instassist without the TUI:
+//
+//	{"cli": "codex", "prompt": "list files"} -> {"options": [...]}
+
+// maxRequestBytes caps a /prompt request body.
+const maxRequestBytes = 1 << 20
+
+// defaultServeCLIs are the CLIs /prompt may run unless serve_clis says
+// otherwise; they are the ones the non-interactive pipeline supports.
+var defaultServeCLIs = []string{"claude", "codex"}
+
+type promptRequest struct {
+	CLI    string `json:"cli"`
+	Prompt string `json:"prompt"`
+}
+
+type promptResponse struct {
+	Options  []optionEntry `json:"options,omitempty"`
+	Warnings []string      `json:"warnings,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// promptQuery runs one prompt; queryOptions in production, a fake in tests.
+type promptQuery func(ctx context.Context, cliName, prompt string) ([]optionEntry, []string, error)
+
+// newServeHandler serves /prompt, running only CLIs in allowed and using
+// defaultCLI when a request doesn't name one.
+func newServeHandler(allowed []string, defaultCLI string, query promptQuery) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /prompt", func(w http.ResponseWriter, r *http.Request) {
+		var req promptRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&req); err != nil {
+			writePromptResponse(w, http.StatusBadRequest, promptResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+			return
+		}
+		if strings.TrimSpace(req.Prompt) == "" {
+			writePromptResponse(w, http.StatusBadRequest, promptResponse{Error: "prompt is required"})
+			return
+		}
+		cliName := strings.ToLower(req.CLI)
+		if cliName == "" {
+			cliName = defaultCLI
+		}
+		if !slices.Contains(allowed, cliName) {
+			writePromptResponse(w, http.StatusForbidden, promptResponse{
+				Error: fmt.Sprintf("cli %q is not allowed (allowed: %s)", cliName, strings.Join(allowed, ", ")),
+			})
+			return
+		}
+		opts, warnings, err := query(r.Context(), cliName, req.Prompt)
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			writePromptResponse(w, http.StatusGatewayTimeout, promptResponse{Warnings: warnings, Error: fmt.Sprintf("%s timed out", cliName)})
+		case err != nil:
+			writePromptResponse(w, http.StatusBadGateway, promptResponse{Warnings: warnings, Error: err.Error()})
+		default:
+			writePromptResponse(w, http.StatusOK, promptResponse{Options: opts, Warnings: warnings})
+		}
+	})
+	return mux
+}
+
+func writePromptResponse(w http.ResponseWriter, status int, resp promptResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// runServe serves /prompt on addr until SIGINT/SIGTERM.
+func runServe(addr string, cfg config) {
+	schema, err := schemaSources(cfg.Schema, cfg.PreferEmbeddedSchema)
+	if err == nil {
+		schema, err = schema.forStyle(cfg.Style)
+	}
+	if err != nil {
+		fatalf("%s", schemaHelp(err))
+	}
+	schema.removeOnTeardown()
+	defer teardown()
+	if schema.warning != "" {
+		log.Printf("warning: %s", schema.warning)
+	}
+
+	allowed := defaultServeCLIs
+	if len(cfg.ServeCLIs) > 0 {
+		allowed = make([]string, len(cfg.ServeCLIs))
+		for i, name := range cfg.ServeCLIs {
+			allowed[i] = strings.ToLower(name)
+		}
+	}
+	query := func(ctx context.Context, cliName, prompt string) ([]optionEntry, []string, error) {
+		// Never auto-approve on behalf of a remote caller.
+		return queryOptions(ctx, cliName, prompt, nil, schema, false, cfg)
+	}
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           newServeHandler(allowed, strings.ToLower(cfg.DefaultCLI), query),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("serving POST /prompt on %s (cli: %s)", addr, strings.Join(allowed, ", "))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatalf("serve error: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package instassist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHandler(t *testing.T) {
+	var gotCLI, gotPrompt string
+	query := func(ctx context.Context, cliName, prompt string) ([]optionEntry, []string, error) {
+		gotCLI, gotPrompt = cliName, prompt
+		switch prompt {
+		case "slow":
+			return nil, nil, fmt.Errorf("CLI error: %w", context.DeadlineExceeded)
+		case "broken":
+			return nil, nil, fmt.Errorf("parse error: no JSON")
+		}
+		return []optionEntry{{Value: "ls -la", Description: "list", RecommendationOrder: 1}}, nil, nil
+	}
+	srv := httptest.NewServer(newServeHandler([]string{"claude"}, "claude", query))
+	defer srv.Close()
+
+	tests := []struct {
+		name, body string
+		status     int
+		wantCLI    string
+	}{
+		{"default cli", `{"prompt":"list files"}`, http.StatusOK, "claude"},
+		{"explicit cli", `{"cli":"Claude","prompt":"list files"}`, http.StatusOK, "claude"},
+		{"not allowed", `{"cli":"codex","prompt":"list files"}`, http.StatusForbidden, ""},
+		{"missing prompt", `{"cli":"claude"}`, http.StatusBadRequest, ""},
+		{"bad json", `{`, http.StatusBadRequest, ""},
+		{"timeout", `{"prompt":"slow"}`, http.StatusGatewayTimeout, "claude"},
+		{"cli failure", `{"prompt":"broken"}`, http.StatusBadGateway, "claude"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotCLI = ""
+			resp, err := http.Post(srv.URL+"/prompt", "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var body promptResponse
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d (error %q)", resp.StatusCode, tt.status, body.Error)
+			}
+			if gotCLI != tt.wantCLI {
+				t.Errorf("ran cli %q, want %q", gotCLI, tt.wantCLI)
+			}
+			if tt.status == http.StatusOK && (len(body.Options) != 1 || body.Options[0].Value != "ls -la" || gotPrompt != "list files") {
+				t.Errorf("unexpected response %+v for prompt %q", body, gotPrompt)
+			}
+			if tt.status != http.StatusOK && body.Error == "" {
+				t.Error("expected an error message")
+			}
+		})
+	}
+
+	resp, err := http.Get(srv.URL + "/prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,163 @@
+package instassist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// shellArgv returns the argv that runs script through the platform shell:
+// sh -c everywhere except Windows, where cmd /C is the shell that is always
+// there.
+func shellArgv(goos, script string) []string {
+	if goos == "windows" {
+		return []string{"cmd", "/C", script}
+	}
+	return []string{"sh", "-c", script}
+}
+
+// shellCommand runs script through the platform shell.
+func shellCommand(ctx context.Context, script string) *exec.Cmd {
+	argv := shellArgv(runtime.GOOS, script)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	setShellCommandLine(cmd, script)
+	return cmd
+}
+
+// captureWaitDelay is how long a captured command's output keeps being read
+// after the command exits or is killed, in case something it started in the
+// background still holds the pipe open.
+const captureWaitDelay = time.Second
+
+// combinedOutput is cmd.CombinedOutput for commands started from the UI.
+// cmd runs in its own process group and cancelling its context kills the
+// whole group, so a backgrounded grandchild can't keep the run hanging past
+// a cancel or timeout. A grandchild that outlives a successful command is
+// left running once captureWaitDelay has passed.
+func combinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	setProcessGroup(cmd)
+	cmd.WaitDelay = captureWaitDelay
+	out, err := cmd.CombinedOutput()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		err = nil
+	}
+	return out, err
+}
+
+// passthroughCommand runs value (or argv without a shell when non-nil) on the
+// real terminal. Under sh a "running:" banner is printed first, so it lands
+// on the normal screen rather than the TUI's alt screen.
+func passthroughCommand(ctx context.Context, goos, value string, argv []string) *exec.Cmd {
+	switch {
+	case goos == "windows" && argv != nil:
+		return exec.CommandContext(ctx, argv[0], argv[1:]...)
+	case goos == "windows":
+		sh := shellArgv(goos, value)
+		cmd := exec.CommandContext(ctx, sh[0], sh[1:]...)
+		setShellCommandLine(cmd, value)
+		return cmd
+	case argv != nil:
+		// The shell only prints the banner; argv reaches exec untouched.
+		args := append([]string{"-c", `printf "→ running: %s\n" "$1" >&2; shift; exec "$@"`, "_", value}, argv...)
+		return exec.CommandContext(ctx, "sh", args...)
+	default:
+		return exec.CommandContext(ctx, "sh", "-c", `printf "→ running: %s\n" "$1" >&2; exec sh -c "$1"`, "_", value)
+	}
+}
+
+// defaultScriptFileBytes is the value length above which a shell value is
+// run from a temporary script file instead of as a `sh -c` argument; Linux
+// refuses single arguments over 128 KiB and Windows whole command lines over
+// 8 KiB.
+const defaultScriptFileBytes = 32 * 1024
+
+// scriptArgv writes value to a temporary script when it is longer than
+// threshold bytes (0 means defaultScriptFileBytes, negative never) and
+// returns the argv that runs it. Shorter values get a nil argv. cleanup
+// removes the file and is always safe to call.
+func scriptArgv(goos, value string, threshold int) (argv []string, cleanup func(), err error) {
+	cleanup = func() {}
+	if threshold == 0 {
+		threshold = defaultScriptFileBytes
+	}
+	if threshold < 0 || len(value) <= threshold {
+		return nil, cleanup, nil
+	}
+	pattern := "instassist-*.sh"
+	if goos == "windows" {
+		pattern = "instassist-*.cmd"
+	}
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("write script: %w", err)
+	}
+	cleanup = func() { _ = os.Remove(f.Name()) }
+	_, err = f.WriteString(value)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cleanup()
+		return nil, func() {}, fmt.Errorf("write script: %w", err)
+	}
+	if goos == "windows" {
+		return []string{"cmd", "/C", f.Name()}, cleanup, nil
+	}
+	return []string{"sh", f.Name()}, cleanup, nil
+}
+
+// scriptLabel stands in for a value run from a script file in the
+// "running:" banner, which would otherwise pass the whole value along.
+func scriptLabel(value string) string {
+	return fmt.Sprintf("%d-line script (%d bytes)", strings.Count(strings.TrimRight(value, "\n"), "\n")+1, len(value))
+}
+
+// sharedSchemaDir is the system-wide schema location `make install` uses,
+// or its Windows counterpart under %ProgramData%.
+func sharedSchemaDir(goos string, getenv func(string) string) string {
+	if goos == "windows" {
+		base := getenv("ProgramData")
+		if base == "" {
+			base = `C:\ProgramData`
+		}
+		return filepath.Join(base, "insta-assist")
+	}
+	return "/usr/local/share/insta-assist"
+}
+
+func defaultSharedSchemaDir() string {
+	return sharedSchemaDir(runtime.GOOS, os.Getenv)
+}
+
+// chainCommandsFor joins values into one script for goos's shell; see
+// chainCommands. cmd has no brace groups, so each value becomes a
+// parenthesized group with its lines joined by &.
+func chainCommandsFor(goos string, values []string, continueOnError bool) string {
+	if goos != "windows" {
+		sep := " && "
+		if continueOnError {
+			sep = "\n"
+		}
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = "{\n" + strings.TrimSpace(v) + "\n}"
+		}
+		return strings.Join(parts, sep)
+	}
+	sep := " && "
+	if continueOnError {
+		sep = " & "
+	}
+	parts := make([]string, len(values))
+	for i, v := range values {
+		lines := strings.Split(strings.TrimSpace(v), "\n")
+		parts[i] = "(" + strings.Join(lines, " & ") + ")"
+	}
+	return strings.Join(parts, sep)
+}
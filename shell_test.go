@@ -0,0 +1,120 @@
+package instassist
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestShellArgv(t *testing.T) {
+	tests := []struct {
+		goos string
+		want []string
+	}{
+		{goos: "linux", want: []string{"sh", "-c", "echo hi"}},
+		{goos: "darwin", want: []string{"sh", "-c", "echo hi"}},
+		{goos: "windows", want: []string{"cmd", "/C", "echo hi"}},
+	}
+	for _, tt := range tests {
+		if got := shellArgv(tt.goos, "echo hi"); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("shellArgv(%q) = %q, want %q", tt.goos, got, tt.want)
+		}
+	}
+}
+
+func TestPassthroughCommandSkipsBannerOnWindows(t *testing.T) {
+	cmd := passthroughCommand(t.Context(), "windows", "dir", nil)
+	if want := []string{"cmd", "/C", "dir"}; !reflect.DeepEqual(cmd.Args, want) {
+		t.Fatalf("args = %q, want %q", cmd.Args, want)
+	}
+	cmd = passthroughCommand(t.Context(), "windows", "git status", []string{"git", "status"})
+	if want := []string{"git", "status"}; !reflect.DeepEqual(cmd.Args, want) {
+		t.Fatalf("argv args = %q, want %q", cmd.Args, want)
+	}
+	cmd = passthroughCommand(t.Context(), "linux", "ls", nil)
+	if cmd.Args[0] != "sh" || cmd.Args[len(cmd.Args)-1] != "ls" {
+		t.Fatalf("linux args = %q, want sh banner wrapper ending in the value", cmd.Args)
+	}
+}
+
+func TestChainCommandsForWindows(t *testing.T) {
+	got := chainCommandsFor("windows", []string{"cd build", "make\nmake test"}, false)
+	if want := "(cd build) && (make & make test)"; got != want {
+		t.Fatalf("chain = %q, want %q", got, want)
+	}
+	got = chainCommandsFor("windows", []string{"a", "b"}, true)
+	if want := "(a) & (b)"; got != want {
+		t.Fatalf("continue chain = %q, want %q", got, want)
+	}
+}
+
+func TestSharedSchemaDir(t *testing.T) {
+	env := func(vals map[string]string) func(string) string {
+		return func(k string) string { return vals[k] }
+	}
+	if got := sharedSchemaDir("linux", env(nil)); got != "/usr/local/share/insta-assist" {
+		t.Fatalf("linux dir = %q", got)
+	}
+	got := sharedSchemaDir("windows", env(map[string]string{"ProgramData": "D:\\Data"}))
+	if want := filepath.Join("D:\\Data", "insta-assist"); got != want {
+		t.Fatalf("windows dir = %q, want %q", got, want)
+	}
+	got = sharedSchemaDir("windows", env(nil))
+	if want := filepath.Join(`C:\ProgramData`, "insta-assist"); got != want {
+		t.Fatalf("windows fallback dir = %q, want %q", got, want)
+	}
+}
+
+func TestScriptArgvWritesLongValuesToAFile(t *testing.T) {
+	if argv, cleanup, err := scriptArgv("linux", "echo hi", 0); argv != nil || err != nil {
+		t.Fatalf("expected a short value to stay inline, got %v (err %v)", argv, err)
+	} else {
+		cleanup()
+	}
+	if argv, _, _ := scriptArgv("linux", "echo hi", -1); argv != nil {
+		t.Fatalf("expected a negative threshold to disable script files, got %v", argv)
+	}
+
+	script := "x=one\necho \"$x two\"\n"
+	argv, cleanup, err := scriptArgv("linux", script, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(argv) != 2 || argv[0] != "sh" {
+		t.Fatalf("expected sh <file>, got %v", argv)
+	}
+	out, err := exec.Command(argv[0], argv[1:]...).Output()
+	if err != nil || string(out) != "one two\n" {
+		t.Fatalf("expected the script to run, got %q (err %v)", out, err)
+	}
+	cleanup()
+	if _, err := os.Stat(argv[1]); !os.IsNotExist(err) {
+		t.Fatalf("expected cleanup to remove %s, got %v", argv[1], err)
+	}
+
+	argv, cleanup, _ = scriptArgv("windows", script, 4)
+	defer cleanup()
+	if len(argv) != 3 || argv[0] != "cmd" || filepath.Ext(argv[2]) != ".cmd" {
+		t.Fatalf("expected cmd /C <file>.cmd on Windows, got %v", argv)
+	}
+}
+
+func TestCapturedRunsDontWaitForBackgroundedChildren(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	msg := execWithFeedback(ctx, "sleep 30 & sleep 30", nil, func() {}, false, true)().(execResultMsg)
+	if elapsed := time.Since(start); elapsed >= captureWaitDelay || !msg.interrupted {
+		t.Fatalf("expected cancel to kill the backgrounded sleep too, took %v (interrupted=%v)", elapsed, msg.interrupted)
+	}
+
+	start = time.Now()
+	msg = pipeWithFeedback(context.Background(), "sleep 5 & cat", "hi")().(execResultMsg)
+	if elapsed := time.Since(start); elapsed > 3*time.Second || msg.err != nil || msg.output != "hi" {
+		t.Fatalf("expected the pipe to finish despite the background sleep, took %v, got %q (err %v)", elapsed, msg.output, msg.err)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+
+package instassist
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup makes cmd lead a new process group and has cancellation
+// kill every process in it, not just cmd.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// setControllingTTY has cmd start a new session whose controlling terminal is
+// its stdout, the pty; stdin may be a pipe carrying the prompt instead.
+func setControllingTTY(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 1}
+}
+
+// setShellCommandLine is only needed on Windows; sh gets script as argv.
+func setShellCommandLine(cmd *exec.Cmd, script string) {}
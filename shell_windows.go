@@ -0,0 +1,24 @@
+package instassist
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup leaves cmd as is on Windows: killing cmd.exe doesn't reach
+// its children there, and captureWaitDelay stops them holding up the run.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// setControllingTTY has nothing to set on Windows, where runCappedTTY isn't
+// supported.
+func setControllingTTY(cmd *exec.Cmd) {}
+
+// setShellCommandLine hands script to cmd.exe verbatim. Go would otherwise
+// quote it as a single argument with backslash escapes cmd doesn't know,
+// mangling any embedded quotes.
+func setShellCommandLine(cmd *exec.Cmd, script string) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CmdLine = "cmd /C " + script
+}
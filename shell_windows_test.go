@@ -0,0 +1,18 @@
+package instassist
+
+import (
+	"context"
+	"testing"
+)
+
+func TestShellCommandKeepsQuotesForCmd(t *testing.T) {
+	script := `echo "a b" && findstr "x y" notes.txt`
+	cmd := shellCommand(context.Background(), script)
+	if cmd.SysProcAttr == nil || cmd.SysProcAttr.CmdLine != "cmd /C "+script {
+		t.Fatalf("expected the raw cmd /C line, got %+v", cmd.SysProcAttr)
+	}
+	cmd = passthroughCommand(context.Background(), "windows", script, nil)
+	if cmd.SysProcAttr == nil || cmd.SysProcAttr.CmdLine != "cmd /C "+script {
+		t.Fatalf("expected the passthrough to use the raw line too, got %+v", cmd.SysProcAttr)
+	}
+}
@@ -0,0 +1,88 @@
+package instassist
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	execModeShell  = "shell"  // run values with sh -c
+	execModeDirect = "direct" // split values into argv and run without a shell
+)
+
+var errNeedsShell = errors.New("uses shell syntax (pipes, redirects, variables, ...); run it with -exec-mode shell")
+
+// splitArgs splits s into argv the way a POSIX shell would for a simple
+// command: whitespace separates words, single quotes are literal, double
+// quotes allow \" \\ \$ and \` escapes, and a backslash outside quotes escapes
+// the next character. Unquoted shell operators and expansions are rejected
+// with errNeedsShell rather than being passed through as literal arguments.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inWord := false
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		case r == '\'':
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			cur.WriteString(string(runes[i+1 : end]))
+			i = end
+			inWord = true
+		case r == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+					i++
+				} else if runes[i] == '$' || runes[i] == '`' {
+					return nil, errNeedsShell
+				}
+				cur.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+			inWord = true
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				if runes[i] != '\n' {
+					cur.WriteRune(runes[i])
+				}
+			}
+			inWord = true
+		case strings.ContainsRune("|&;<>()$`*?[]{}~#", r) && !(r == '#' && inWord) && !(r == '~' && inWord):
+			return nil, errNeedsShell
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		args = append(args, cur.String())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return args, nil
+}
+
+func indexRune(runes []rune, from int, target rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == target {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,44 @@
+package instassist
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{in: "ls -la", want: []string{"ls", "-la"}},
+		{in: `grep -rn 'TODO: fix' .`, want: []string{"grep", "-rn", "TODO: fix", "."}},
+		{in: `echo "say \"hi\"" done`, want: []string{"echo", `say "hi"`, "done"}},
+		{in: `touch my\ file`, want: []string{"touch", "my file"}},
+		{in: `git commit -m ''`, want: []string{"git", "commit", "-m", ""}},
+		{in: "  tar  -czf a.tgz\tdir  ", want: []string{"tar", "-czf", "a.tgz", "dir"}},
+		{in: `find . -name '*.go'`, want: []string{"find", ".", "-name", "*.go"}},
+	}
+	for _, tt := range tests {
+		got, err := splitArgs(tt.in)
+		if err != nil {
+			t.Fatalf("splitArgs(%q) error: %v", tt.in, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("splitArgs(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitArgsRejectsShellSyntax(t *testing.T) {
+	for _, in := range []string{"ls | wc -l", "make && make install", "echo $HOME", `echo "$HOME"`, "cat < in", "ls *.go", "echo `date`"} {
+		if _, err := splitArgs(in); !errors.Is(err, errNeedsShell) {
+			t.Fatalf("splitArgs(%q) = %v, want errNeedsShell", in, err)
+		}
+	}
+	for _, in := range []string{`echo 'open`, `echo "open`, "   "} {
+		if _, err := splitArgs(in); err == nil || errors.Is(err, errNeedsShell) {
+			t.Fatalf("splitArgs(%q) = %v, want a syntax error", in, err)
+		}
+	}
+}
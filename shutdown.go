@@ -0,0 +1,36 @@
+package instassist
+
+import (
+	"log"
+	"sync"
+)
+
+// Teardown hooks release resources (such as the temp schema file) on every
+// exit path: normal quit, signals handled by Bubble Tea, and fatal errors.
+var (
+	teardownMu    sync.Mutex
+	teardownHooks []func()
+)
+
+func onTeardown(fn func()) {
+	teardownMu.Lock()
+	defer teardownMu.Unlock()
+	teardownHooks = append(teardownHooks, fn)
+}
+
+// teardown runs the registered hooks in reverse order, at most once each.
+func teardown() {
+	teardownMu.Lock()
+	hooks := teardownHooks
+	teardownHooks = nil
+	teardownMu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
+// fatalf is log.Fatalf that runs teardown first, since os.Exit skips defers.
+func fatalf(format string, args ...any) {
+	teardown()
+	log.Fatalf(format, args...)
+}
@@ -0,0 +1,36 @@
+package instassist
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestTeardownRunsHooksOnceInReverse(t *testing.T) {
+	var order []int
+	onTeardown(func() { order = append(order, 1) })
+	onTeardown(func() { order = append(order, 2) })
+
+	teardown()
+	teardown()
+
+	if !reflect.DeepEqual(order, []int{2, 1}) {
+		t.Fatalf("expected hooks to run once in reverse, got %v", order)
+	}
+}
+
+func TestEmbeddedSchemaTempFileRemoved(t *testing.T) {
+	t.Chdir(t.TempDir())
+	src, err := schemaSources("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !src.temp {
+		t.Fatal("expected the embedded schema to be written to a temp file")
+	}
+	src.removeOnTeardown()
+	teardown()
+	if _, err := os.Stat(src.path); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be removed, stat err: %v", src.path, err)
+	}
+}
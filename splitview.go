@@ -0,0 +1,81 @@
+package instassist
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// minSplitWidth is the narrowest window that shows the split view; below it
+// the raw pane would leave too little room for the options.
+const minSplitWidth = 100
+
+// splitActive reports whether the options are shown beside the raw reply.
+func (m model) splitActive() bool {
+	return m.splitView && m.width >= minSplitWidth && len(m.options) > 0
+}
+
+func (m *model) toggleSplitView() {
+	m.splitView = !m.splitView
+	m.rawScroll = 0
+	switch {
+	case !m.splitView:
+		m.status = helpViewing
+	case m.width < minSplitWidth:
+		m.status = fmt.Sprintf("split view needs %d columns (window has %d) • %s", minSplitWidth, m.width, helpViewing)
+	default:
+		m.status = "split view • J/K: scroll raw reply • s: close"
+	}
+}
+
+func (m model) splitWidths() (left, right int) {
+	left = (m.width - 3) / 2
+	return left, m.width - 3 - left
+}
+
+// splitRawLines is the raw reply for the right pane, indented when it is
+// JSON and wrapped to the pane.
+func (m model) splitRawLines() []string {
+	text := m.rawOutput
+	var indented bytes.Buffer
+	if json.Indent(&indented, []byte(text), "", "  ") == nil {
+		text = indented.String()
+	}
+	_, right := m.splitWidths()
+	wrapped := lipgloss.NewStyle().Width(max(10, right-2)).Render(text)
+	return strings.Split(wrapped, "\n")
+}
+
+func (m *model) scrollSplit(delta int) {
+	lines := len(m.splitRawLines())
+	m.rawScroll = max(0, min(m.rawScroll+delta, lines-m.rawViewHeight()))
+}
+
+// renderSplitView lays the options table out on the left and the scrollable
+// raw reply on the right.
+func (m model) renderSplitView() string {
+	left, right := m.splitWidths()
+	narrow := m
+	narrow.width = left
+	options := lipgloss.NewStyle().Width(left).Render(narrow.renderOptionsTable())
+
+	lines := m.splitRawLines()
+	end := min(len(lines), m.rawScroll+m.rawViewHeight())
+	rawStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(grayColor))
+	pane := rawStyle.Render(strings.Join(lines[m.rawScroll:end], "\n"))
+	if len(lines) > m.rawViewHeight() {
+		pane += "\n" + rawStyle.Italic(true).Render(fmt.Sprintf("lines %d-%d of %d", m.rawScroll+1, end, len(lines)))
+	}
+	raw := lipgloss.NewStyle().
+		Width(right).
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderLeft(true).
+		BorderForeground(lipgloss.Color(grayColor)).
+		PaddingLeft(1).
+		Render(pane)
+
+	return lipgloss.JoinHorizontal(lipgloss.Top, options, "  ", raw)
+}
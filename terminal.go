@@ -0,0 +1,37 @@
+package instassist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// limitedTerminal reports whether the TUI would come out garbled: a dumb
+// terminal, or stdout that isn't a terminal at all (CI logs, some remote
+// shells).
+func limitedTerminal(term string, stdoutTTY bool) bool {
+	return term == "dumb" || !stdoutTTY
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// readPlainPrompt asks for a single prompt line without any escape sequences,
+// the fallback when limitedTerminal rules out the TUI.
+func readPlainPrompt(in io.Reader, out io.Writer) (string, error) {
+	fmt.Fprint(out, "prompt> ")
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	prompt := strings.TrimSpace(line)
+	if prompt == "" {
+		return "", errors.New("no prompt given")
+	}
+	return prompt, nil
+}
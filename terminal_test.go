@@ -0,0 +1,33 @@
+package instassist
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLimitedTerminal(t *testing.T) {
+	if !limitedTerminal("dumb", true) {
+		t.Error("TERM=dumb should be limited")
+	}
+	if !limitedTerminal("xterm-256color", false) {
+		t.Error("a non-TTY stdout should be limited")
+	}
+	if limitedTerminal("xterm-256color", true) {
+		t.Error("a TTY with a real TERM should not be limited")
+	}
+}
+
+func TestReadPlainPrompt(t *testing.T) {
+	var out bytes.Buffer
+	got, err := readPlainPrompt(strings.NewReader("  list files  \nignored\n"), &out)
+	if err != nil || got != "list files" {
+		t.Fatalf("readPlainPrompt = %q, %v; want %q", got, err, "list files")
+	}
+	if out.String() != "prompt> " {
+		t.Errorf("printed %q, want a plain prompt marker", out.String())
+	}
+	if _, err := readPlainPrompt(strings.NewReader(""), &out); err == nil {
+		t.Error("expected an error for an empty prompt")
+	}
+}
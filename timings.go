@@ -0,0 +1,30 @@
+package instassist
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timings accumulates where a session's time went, for -timings.
+type timings struct {
+	subprocess time.Duration // waiting on CLI processes
+	parse      time.Duration // extracting options from replies
+	calls      int
+	cached     int
+}
+
+// summary breaks total down into subprocess, parse and idle time; idle is
+// whatever remains (typing, reading, choosing).
+func (t timings) summary(total time.Duration) string {
+	idle := total - t.subprocess - t.parse
+	if idle < 0 {
+		idle = 0
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "timings: %s total\n", total.Round(time.Millisecond))
+	fmt.Fprintf(&b, "  subprocess %10s  (%d calls, %d cached)\n", t.subprocess.Round(time.Millisecond), t.calls, t.cached)
+	fmt.Fprintf(&b, "  parse      %10s\n", t.parse.Round(time.Microsecond))
+	fmt.Fprintf(&b, "  idle       %10s\n", idle.Round(time.Millisecond))
+	return b.String()
+}
@@ -0,0 +1,96 @@
+package instassist
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// exchange is one prompt/reply round trip for -transcript, with what the
+// user did with the result.
+type exchange struct {
+	at      time.Time
+	prompt  string
+	cli     string
+	raw     string
+	err     string
+	options []optionEntry
+	actions []string // "copied: ..." / "ran: ..."
+}
+
+// recordExchange appends the response just handled, as the model now shows it.
+// The prompt is what the user typed unless store_full_prompt asks for the
+// assembled one, with instructions and attachments.
+func (m *model) recordExchange(msg responseMsg) {
+	prompt := m.lastPrompt
+	if m.storeFullPrompt && m.lastFullPrompt != "" {
+		prompt = m.lastFullPrompt
+	}
+	ex := exchange{
+		at:      time.Now(),
+		prompt:  prompt,
+		cli:     msg.cli,
+		raw:     m.rawOutput,
+		options: m.options,
+	}
+	if m.lastError != nil {
+		ex.err = m.lastError.Error()
+	} else if m.lastParseError != nil {
+		ex.err = m.lastParseError.Error()
+	}
+	m.transcript = append(m.transcript, ex)
+}
+
+// recordAction notes a copy or run against the latest exchange.
+func (m *model) recordAction(kind, value string) {
+	if len(m.transcript) == 0 {
+		return
+	}
+	last := &m.transcript[len(m.transcript)-1]
+	last.actions = append(last.actions, kind+": "+value)
+}
+
+func formatTranscript(started time.Time, exchanges []exchange) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# instassist session %s\n", started.Format(time.RFC3339))
+	if len(exchanges) == 0 {
+		b.WriteString("\n(no prompts sent)\n")
+	}
+	for i, ex := range exchanges {
+		fmt.Fprintf(&b, "\n## %d. %s at %s\n\n", i+1, ex.cli, ex.at.Format(time.TimeOnly))
+		fmt.Fprintf(&b, "Prompt:\n%s\n", indentBlock(ex.prompt))
+		if ex.err != "" {
+			fmt.Fprintf(&b, "\nError: %s\n", ex.err)
+		}
+		if len(ex.options) > 0 {
+			b.WriteString("\nOptions:\n")
+			for j, opt := range ex.options {
+				fmt.Fprintf(&b, "  %d. %s", j+1, opt.Value)
+				if opt.Description != "" {
+					fmt.Fprintf(&b, " — %s", opt.Description)
+				}
+				b.WriteString("\n")
+			}
+		}
+		for _, action := range ex.actions {
+			fmt.Fprintf(&b, "\n%s\n", action)
+		}
+		fmt.Fprintf(&b, "\nRaw output:\n%s\n", indentBlock(ex.raw))
+	}
+	return b.String()
+}
+
+func indentBlock(s string) string {
+	if s == "" {
+		return "  (empty)"
+	}
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ")
+}
+
+func writeTranscript(path string, started time.Time, exchanges []exchange) error {
+	if err := os.WriteFile(path, []byte(formatTranscript(started, exchanges)), 0o600); err != nil {
+		return fmt.Errorf("write transcript: %w", err)
+	}
+	return nil
+}
@@ -2,14 +2,16 @@ package instassist
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
+	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -21,11 +23,55 @@ const (
 
 	grayColor = "250"
 
-	helpInput   = "enter: send • ctrl+r: send & run • ctrl+y: toggle yolo • alt+enter/ctrl+j: newline • esc: exit"
-	helpViewing = "enter: copy & exit • ctrl+r: run & exit • a: refine • n: new prompt • ctrl+y: toggle yolo • esc/q: quit"
-	helpRefine  = "enter: refine • ctrl+r: refine & run • ctrl+y: toggle yolo • alt+enter/ctrl+j: newline • esc: exit"
+	// The default statuses only point at the help overlay, which lists
+	// every binding; the short, mode-specific ones below stay spelled out.
+	helpInput   = "enter: send • f1 for help"
+	helpViewing = "enter: copy & exit • ? for help"
+	helpPicker  = "↑/↓: choose • enter: select • esc: cancel"
+	helpRaw     = "j/k: scroll • ? for help"
+	helpExplain = "esc/e: back to options • j/k: scroll • enter: copy explanation"
+	helpConfirm = "y/enter: run • n/esc: cancel"
+	helpRefine  = "enter: refine • f1 for help"
 )
 
+// iconSet holds every emoji the UI prints so terminals without emoji support
+// can swap in ASCII with a single assignment.
+type iconSet struct {
+	ok      string
+	fail    string
+	warn    string
+	hint    string
+	loading string
+	logo    string
+	suggest string
+}
+
+var (
+	emojiIcons = iconSet{ok: "✅", fail: "❌", warn: "⚠", hint: "💡", loading: "⏳", logo: "✨", suggest: "★"}
+	asciiIcons = iconSet{ok: "[ok]", fail: "[x]", warn: "[!]", hint: "[?]", loading: "[..]", logo: "*", suggest: "(*)"}
+
+	icons = emojiIcons
+)
+
+// asciiTerminal reports whether the terminal is unlikely to render emoji:
+// dumb/linux consoles or a non-UTF-8 locale.
+func asciiTerminal() bool {
+	switch os.Getenv("TERM") {
+	case "dumb", "linux":
+		return true
+	}
+	if runtime.GOOS == "windows" {
+		return false
+	}
+	for _, key := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := os.Getenv(key); v != "" {
+			v = strings.ToLower(v)
+			return !strings.Contains(v, "utf-8") && !strings.Contains(v, "utf8")
+		}
+	}
+	return false
+}
+
 type viewMode int
 
 const (
@@ -33,25 +79,47 @@ const (
 	modeRunning
 	modeViewing
 	modeRefine
+	modePickCLI
+	modeNameFavorite
+	modePickFavorite
 )
 
 type responseMsg struct {
 	output []byte
 	err    error
 	cli    string
+	argv   []string
+
+	// processed is the postprocess hook's stdout; nil when no hook ran or it
+	// failed (see postprocessErr), in which case output is parsed directly.
+	processed      []byte
+	postprocessErr error
+
+	cached   bool   // output came from the response cache
+	cacheKey string // set when a fresh response may be cached
+
+	gen       int           // runGen when dispatched
+	fanOut    bool          // one CLI's reply to a fan-out (ctrl+x)
+	truncated bool          // output exceeded the capture limit
+	elapsed   time.Duration // CLI run time; zero for cache hits
 }
 
 type execResultMsg struct {
-	err    error
-	exit   bool
-	output string
+	err         error
+	exit        bool
+	output      string
+	interrupted bool
 }
 
 type tickMsg struct{}
 
-func tickCmd() tea.Msg {
-	time.Sleep(80 * time.Millisecond)
-	return tickMsg{}
+const defaultTickInterval = 80 * time.Millisecond
+
+func tickCmd(interval time.Duration) tea.Cmd {
+	return func() tea.Msg {
+		time.Sleep(interval)
+		return tickMsg{}
+	}
 }
 
 type clickRegion struct {
@@ -70,20 +138,58 @@ type headerMeta struct {
 
 type cliOption struct {
 	name         string
-	runPrompt    func(ctx context.Context, prompt string, yolo bool) ([]byte, error)
-	resumePrompt func(ctx context.Context, prompt string, sessionID string, yolo bool) ([]byte, error)
+	runPrompt    func(ctx context.Context, prompt string, yolo bool) *exec.Cmd
+	resumePrompt func(ctx context.Context, prompt string, sessionID string, yolo bool) *exec.Cmd
+	// runRaw asks for a plain-text reply without the options schema (-raw).
+	runRaw func(ctx context.Context, prompt string, yolo bool) *exec.Cmd
+	// run executes a command built above and captures its output; nil is
+	// runCapped.
+	run func(cmd *exec.Cmd, limit int) ([]byte, bool, error)
+}
+
+// capture runs cmd the way this CLI needs.
+func (c cliOption) capture(cmd *exec.Cmd, limit int) ([]byte, bool, error) {
+	if c.run == nil {
+		return runCapped(cmd, limit)
+	}
+	return c.run(cmd, limit)
 }
 
 type model struct {
 	cliOptions []cliOption
 	cliIndex   int
+	lastCLI    string // CLI selected before the current one, for ctrl+l / "-"
 
 	input textarea.Model
 
 	mode         viewMode
 	running      bool
 	stayOpenExec bool
-	yolo         bool
+	keepOpen     bool // return to results after a passthrough exec
+	stickyPrompt bool // new prompts start from the last one, see carriedPrompt
+	present      bool // presentation styling for demos/screen-sharing
+	daemon       bool // resident mode: finish a request by waiting, not exiting
+	autoSubmit   bool // submit the pre-filled prompt on start (-submit)
+	acted        bool // a value was copied or run; decides the exit code
+	attachments  []attachment
+	execMode     string
+	execTemplate string // wraps values before they run, see applyExecTemplate
+	trigger      chan<- string
+	session      bool // new prompts resume the current CLI's last session
+	timings      timings
+	pipeCommand  string
+	onCopy       string
+	postprocess  string // shell command the CLI output is filtered through before parsing
+	cache        *responseCache
+
+	// scriptFileBytes is the value length above which shell values run
+	// from a temporary script file, see scriptArgv.
+	scriptFileBytes int
+
+	maxOutputBytes int
+	promptLimit    promptLimit            // global prompt size limits
+	promptLimits   map[string]promptLimit // per-CLI overrides of promptLimit
+	yolo           bool
 
 	width  int
 	height int
@@ -92,52 +198,143 @@ type model struct {
 	lastPrompt string
 	status     string
 
-	rawOutput  string
-	execOutput string
+	rawOutput   string
+	execOutput  string
+	lastArgv    []string // command line of the last CLI run
+	showArgv    bool
+	lastRun     runInfo
+	clarifying  string // question the CLI asked instead of answering
+	explanation string // detail view for the selected option ("e")
+	showHelp    bool   // help overlay covers the current mode
 
 	options        []optionEntry
 	selected       int
 	lastParseError error
 	lastError      error
 
-	autoExecute bool // if true, execute first result and exit
+	reasoning     string // the model's reasoning for the last reply, if it sent any
+	showReasoning bool   // reasoning panel open ("w", -explain)
+
+	// optionBlocks holds every options block in the last reply when there
+	// was more than one; "b" cycles through them.
+	optionBlocks [][]optionEntry
+	blockIndex   int
+
+	previousOptions []optionEntry // options before the last regenerate, for diffing
+	hideDiff        bool
+	viewDetail      viewDetail
+	marked          map[int]bool // option indexes marked for a multi-run
+	continueOnError bool
+
+	lastClickIndex int // option under the last click, for double-click
+	lastClickAt    time.Time
 
-	spinnerFrame int // for animation while waiting
+	favoritesPath string
+	favorites     []favorite // loaded while the favorites picker is open
+	favoriteIndex int
+	favoriteName  string // name being typed for ctrl+s
+
+	pickerIndex  int      // highlighted row in the CLI picker
+	pickerReturn viewMode // mode to restore when the picker closes
+
+	autoExecute bool // if true, execute first result and exit
+	execCancel  context.CancelFunc
+	runCancel   context.CancelFunc // cancels the CLI request in flight
+	runGen      int                // bumped when a request is abandoned; older replies are dropped
+	fanOut      *fanOut            // replies collected so far while a ctrl+x fan-out runs
+	confirm     bool               // show the command and wait for y before running
+	pendingRun  *pendingRun        // command awaiting confirmation
+	execPolicy  execPolicy
+	blockedRun  string // value refused by execPolicy; ctrl+r again overrides
+
+	spinnerFrame int  // for animation while waiting
+	ticking      bool // a tickMsg is in flight
+	blurred      bool // terminal lost focus; spinner paused
 
 	sessionIDs      map[string]string
 	pendingResumeID string
 	promptHistory   []string
+
+	promptTemplates map[string]string
+	promptPrefix    string
+	language        string
+	maxOrder        int
+	dropUnordered   bool
+	maxValueWidth   int
+	parseMode       string
+	sortBy          string
+	showInputHint   bool // explains the options contract until the first success
+	raw             bool // show the reply as-is instead of parsing options
+	tickInterval    time.Duration
+	noColor         bool
+	numbered        bool // prefix rows with their 1-based index
+	autoSelect      bool // select and tag the top recommendation (-auto-select)
+	extraFields     []extraField
+	hideOrder       bool // show_order: false; never display recommendation_order
+	suggested       int  // index of the tagged option, or -1
+	markdown        bool // render the detail view as markdown
+	style           string
+	appendClipboard bool // copies add to the clipboard instead of replacing it
+	copyNewline     bool // copies end with a newline, see withCopyNewline
+	appendSeparator string
+	timeout         duration
+	timeouts        map[string]duration // per-CLI overrides of timeout
+	rawScroll       int
+	splitView       bool // options beside the raw reply ("s")
+	stash           *resultsStash
+	transcript      []exchange // every reply this session, for -transcript
+	storeFullPrompt bool       // transcripts keep the assembled prompt, not the typed one
+	lastFullPrompt  string     // what dispatchPrompt last sent, after buildPrompt
+
+	// Parse-failure retries re-send lastDispatch (the prompt content before
+	// buildPrompt) to the same session.
+	maxParseRetries     int
+	parseRetries        int
+	maxEmptyRetries     int // same, for replies with zero options
+	emptyRetries        int
+	lastDispatch        string
+	lastDispatchSession string
 }
 
-func newModel(defaultCLI string, stayOpenExec bool, yoloDefault bool) model {
-	schemaPath, schemaJSON, err := schemaSources()
+func newModel(defaultCLI string, stayOpenExec bool, yoloDefault bool, cfg config) model {
+	schema, err := schemaSources(cfg.Schema, cfg.PreferEmbeddedSchema)
+	if err == nil {
+		schema, err = schema.forStyle(cfg.Style)
+	}
 	if err != nil {
 		logFatalSchema(err)
 	}
+	schema.removeOnTeardown()
+	schemaPath, schemaJSON := schema.path, schema.json
 
 	allCLIOptions := []cliOption{
 		{
 			name: "claude",
-			runPrompt: func(ctx context.Context, prompt string, yolo bool) ([]byte, error) {
+			runPrompt: func(ctx context.Context, prompt string, yolo bool) *exec.Cmd {
 				args := []string{"-p", prompt, "--print", "--output-format", "json", "--json-schema", schemaJSON}
 				if yolo {
 					args = append(args, "--dangerously-skip-permissions")
 				}
-				cmd := exec.CommandContext(ctx, "claude", args...)
-				return cmd.CombinedOutput()
+				return exec.CommandContext(ctx, "claude", args...)
 			},
-			resumePrompt: func(ctx context.Context, prompt string, sessionID string, yolo bool) ([]byte, error) {
+			resumePrompt: func(ctx context.Context, prompt string, sessionID string, yolo bool) *exec.Cmd {
 				args := []string{"-p", prompt, "--print", "--output-format", "json", "--json-schema", schemaJSON, "--resume", sessionID}
 				if yolo {
 					args = append(args, "--dangerously-skip-permissions")
 				}
-				cmd := exec.CommandContext(ctx, "claude", args...)
-				return cmd.CombinedOutput()
+				return exec.CommandContext(ctx, "claude", args...)
+			},
+			runRaw: func(ctx context.Context, prompt string, yolo bool) *exec.Cmd {
+				args := []string{"-p", prompt, "--print", "--output-format", "text"}
+				if yolo {
+					args = append(args, "--dangerously-skip-permissions")
+				}
+				return exec.CommandContext(ctx, "claude", args...)
 			},
 		},
 		{
 			name: "codex",
-			runPrompt: func(ctx context.Context, prompt string, yolo bool) ([]byte, error) {
+			runPrompt: func(ctx context.Context, prompt string, yolo bool) *exec.Cmd {
 				args := []string{"exec"}
 				if yolo {
 					args = append(args, "--yolo")
@@ -145,9 +342,9 @@ func newModel(defaultCLI string, stayOpenExec bool, yoloDefault bool) model {
 				args = append(args, "--output-schema", schemaPath, "--skip-git-repo-check", "--json")
 				cmd := exec.CommandContext(ctx, "codex", args...)
 				cmd.Stdin = strings.NewReader(prompt)
-				return cmd.CombinedOutput()
+				return cmd
 			},
-			resumePrompt: func(ctx context.Context, prompt string, sessionID string, yolo bool) ([]byte, error) {
+			resumePrompt: func(ctx context.Context, prompt string, sessionID string, yolo bool) *exec.Cmd {
 				args := []string{"exec"}
 				if yolo {
 					args = append(args, "--yolo")
@@ -155,7 +352,17 @@ func newModel(defaultCLI string, stayOpenExec bool, yoloDefault bool) model {
 				args = append(args, "--output-schema", schemaPath, "--skip-git-repo-check", "--json", "resume", sessionID, "-")
 				cmd := exec.CommandContext(ctx, "codex", args...)
 				cmd.Stdin = strings.NewReader(prompt)
-				return cmd.CombinedOutput()
+				return cmd
+			},
+			runRaw: func(ctx context.Context, prompt string, yolo bool) *exec.Cmd {
+				args := []string{"exec"}
+				if yolo {
+					args = append(args, "--yolo")
+				}
+				args = append(args, "--skip-git-repo-check")
+				cmd := exec.CommandContext(ctx, "codex", args...)
+				cmd.Stdin = strings.NewReader(prompt)
+				return cmd
 			},
 		},
 	}
@@ -163,14 +370,28 @@ func newModel(defaultCLI string, stayOpenExec bool, yoloDefault bool) model {
 	var cliOptions []cliOption
 	for _, opt := range allCLIOptions {
 		if cliAvailable(opt.name) {
+			opt.run = runner(opt.name, cfg.TTYCLIs)
 			cliOptions = append(cliOptions, opt)
 		}
 	}
 
 	if len(cliOptions) == 0 {
-		logFatalSchema(fmt.Errorf("no AI CLIs found. Please install at least one of: claude, codex"))
+		fatalf("no AI CLIs found. Please install at least one of: claude, codex")
+	}
+
+	m := newModelWithCLIs(cliOptions, defaultCLI, stayOpenExec, yoloDefault, cfg)
+	if !cfg.NoCache {
+		m.cache = newResponseCache(time.Duration(cfg.CacheTTL))
 	}
+	if schema.warning != "" {
+		m.status = icons.warn + " " + schema.warning
+	}
+	return m
+}
 
+// newModelWithCLIs builds the model around an explicit set of CLIs, skipping
+// schema lookup and PATH detection.
+func newModelWithCLIs(cliOptions []cliOption, defaultCLI string, stayOpenExec bool, yoloDefault bool, cfg config) model {
 	input := textarea.New()
 	input.Placeholder = "Enter prompt"
 	input.Focus()
@@ -194,12 +415,62 @@ func newModel(defaultCLI string, stayOpenExec bool, yoloDefault bool) model {
 		mode:         modeInput,
 		status:       helpInput,
 		stayOpenExec: stayOpenExec,
+		keepOpen:     cfg.KeepOpen,
+		stickyPrompt: cfg.StickyPrompt,
+		present:      cfg.Present,
+		session:      cfg.Session,
+		execMode:     cfg.ExecMode,
+		execTemplate: cfg.ExecTemplate,
+		pipeCommand:  cfg.Pipe,
+		onCopy:       cfg.OnCopy,
+		postprocess:  cfg.Postprocess,
 		yolo:         yoloDefault,
 		sessionIDs:   map[string]string{},
+
+		continueOnError: cfg.ContinueOnError,
+		scriptFileBytes: cfg.ScriptFileBytes,
+		maxOutputBytes:  cfg.MaxOutputBytes,
+		promptLimit:     promptLimit{Warn: cfg.PromptWarnChars, Max: cfg.PromptMaxChars},
+		promptLimits:    cfg.PromptLimits,
+		promptTemplates: cfg.PromptTemplates,
+		promptPrefix:    cfg.PromptPrefix,
+		language:        cfg.Language,
+		maxOrder:        cfg.MaxOrder,
+		dropUnordered:   cfg.DropUnordered,
+		maxValueWidth:   cfg.MaxValueWidth,
+		parseMode:       cfg.ParseMode,
+		sortBy:          cfg.SortBy,
+		showInputHint:   !cfg.HideInputHint,
+		raw:             cfg.Raw,
+		tickInterval:    time.Duration(cfg.TickInterval),
+		noColor:         cfg.NoColor,
+		numbered:        cfg.Numbered,
+		autoSelect:      cfg.AutoSelect,
+		showReasoning:   cfg.Explain,
+		extraFields:     cfg.ExtraFields,
+		hideOrder:       cfg.ShowOrder != nil && !*cfg.ShowOrder,
+		suggested:       -1,
+		markdown:        cfg.Markdown,
+		style:           cfg.Style,
+		appendClipboard: cfg.Append,
+		copyNewline:     cfg.CopyNewline,
+		appendSeparator: cfg.AppendSeparator,
+		timeout:         cfg.Timeout,
+		timeouts:        cfg.Timeouts,
+		storeFullPrompt: cfg.StoreFullPrompt,
+		confirm:         cfg.Confirm,
+		maxParseRetries: cfg.MaxParseRetries,
+		maxEmptyRetries: cfg.MaxEmptyRetries,
 	}
 }
 
+// autoSubmitMsg sends the pre-filled prompt once the program is running.
+type autoSubmitMsg struct{}
+
 func (m model) Init() tea.Cmd {
+	if m.autoSubmit {
+		return func() tea.Msg { return autoSubmitMsg{} }
+	}
 	return nil
 }
 
@@ -213,27 +484,79 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.adjustTextareaHeight()
 		return m, nil
 	case tickMsg:
-		if m.running {
+		m.ticking = false
+		if m.running && !m.blurred {
 			m.spinnerFrame = (m.spinnerFrame + 1) % 10
-			return m, tickCmd
+			return m, m.startTicking()
+		}
+		return m, nil
+	case tea.BlurMsg:
+		// Let the in-flight tick lapse; no redraws while unfocused.
+		m.blurred = true
+		return m, nil
+	case tea.FocusMsg:
+		m.blurred = false
+		if m.running {
+			return m, m.startTicking()
 		}
 		return m, nil
 	case responseMsg:
-		return m.handleResponse(msg)
+		if !m.running || msg.gen != m.runGen {
+			// The run was dismissed (daemon mode) or abandoned for another
+			// CLI; drop its late reply.
+			return m, nil
+		}
+		if msg.fanOut {
+			return m.handleFanOutResponse(msg)
+		}
+		next, cmd := m.handleResponse(msg)
+		nm := next.(model)
+		nm.recordExchange(msg)
+		return nm, cmd
+	case fanOutExpiredMsg:
+		return m.handleFanOutExpired(msg)
+	case triggerMsg:
+		return m.handleTrigger(msg)
+	case explainMsg:
+		return m.handleExplainMsg(msg)
+	case autoSubmitMsg:
+		return m.submitPrompt()
+	case urlOpenedMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("%s could not open %s: %v", icons.fail, msg.url, msg.err)
+			return m, nil
+		}
+		m.status = fmt.Sprintf("%s opened %s", icons.ok, msg.url)
+		m.acted = true
+		return m, nil
 	case execResultMsg:
+		if m.execCancel != nil {
+			m.execCancel()
+			m.execCancel = nil
+		}
 		m.running = false
 		m.mode = modeViewing
 		m.execOutput = msg.output
+		// The terminal may have been resized while a passthrough command owned
+		// it, and no WindowSizeMsg arrives for that; ask for the size again.
+		m.resizeComponents()
+		resize := tea.WindowSize()
+		if msg.interrupted {
+			m.status = icons.warn + " execution interrupted • " + helpViewing
+			return m, resize
+		}
 		m.lastError = msg.err
 		if msg.exit {
+			m.acted = true
 			return m, tea.Quit
 		}
 		if msg.err != nil {
-			m.status = fmt.Sprintf("❌ exec failed: %v • %s", msg.err, helpViewing)
-			return m, nil
+			m.status = fmt.Sprintf("%s exec failed: %v • %s", icons.fail, msg.err, helpViewing)
+			return m, resize
 		}
+		m.acted = true
 		m.status = "command finished • " + helpViewing
-		return m, nil
+		return m, resize
 	case tea.KeyMsg:
 		return m.handleKeyMsg(msg)
 	case tea.MouseMsg:
@@ -252,16 +575,35 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m model) handleResponse(msg responseMsg) (tea.Model, tea.Cmd) {
 	m.running = false
+	m.runCancel = nil
 	m.mode = modeViewing
 
-	respText := strings.TrimSpace(string(msg.output))
+	// Invalid UTF-8 would garble rendering and throw off width measurement.
+	respText := strings.TrimSpace(strings.ToValidUTF8(string(msg.output), "\uFFFD"))
 	if msg.err != nil && respText == "" {
 		respText = msg.err.Error()
 	}
 	m.rawOutput = respText
+	m.lastArgv = msg.argv
+	m.lastRun = runInfo{cli: msg.cli, elapsed: msg.elapsed, cached: msg.cached}
+	m.timings.subprocess += msg.elapsed
+	if msg.cached {
+		m.timings.cached++
+	} else {
+		m.timings.calls++
+	}
+	m.clarifying = ""
+	m.explanation = ""
+	m.marked = nil
+	// A run held for -confirm or refused by the policy belongs to the old
+	// options; never let y or a second ctrl+r run it over the new ones.
+	m.pendingRun = nil
+	m.blockedRun = ""
 	m.lastParseError = nil
 	m.lastError = nil
 	m.execOutput = ""
+	m.optionBlocks = nil
+	m.reasoning = extractReasoning(respText)
 
 	if sessionID := extractSessionID(respText); sessionID != "" {
 		if m.sessionIDs == nil {
@@ -270,38 +612,129 @@ func (m model) handleResponse(msg responseMsg) (tea.Model, tea.Cmd) {
 		m.sessionIDs[msg.cli] = sessionID
 	}
 
+	truncNote := ""
+	if msg.truncated {
+		truncNote = fmt.Sprintf("%s output truncated at %d bytes • ", icons.warn, len(msg.output))
+	}
+
 	if msg.err != nil {
+		if partial := recoverPartialOptions(respText, m.sortBy); len(partial) > 0 {
+			return m.showRecovered(partial, m.runFailure(msg.cli, msg.err))
+		}
 		m.lastError = msg.err
-		m.status = fmt.Sprintf("error from %s: %v • %s", msg.cli, msg.err, helpViewing)
+		m.status = fmt.Sprintf("%s%s • %s", truncNote, m.runFailure(msg.cli, msg.err), helpViewing)
+		if hint := authHint(msg.cli, respText); hint != "" {
+			m.status = fmt.Sprintf("%s%s %s • %s", truncNote, icons.fail, hint, helpViewing)
+		}
 		m.options = nil
 		m.selected = 0
 		return m, nil
 	}
 
-	opts, parseErr := extractOptions(respText)
+	parseText := respText
+	if msg.processed != nil {
+		parseText = strings.ToValidUTF8(string(msg.processed), "\uFFFD")
+	}
+	if m.raw {
+		return m.showRawResponse(parseText, msg)
+	}
+	parseStart := time.Now()
+	blocks, opts, parseErr := extractOptionBlocks(parseText, m.parseMode, m.sortBy)
+	m.timings.parse += time.Since(parseStart)
+	if parseErr != nil {
+		if question := clarifyingQuestion(parseText); question != "" {
+			m.clarifying = question
+			m.mode = modeRefine
+			m.input.SetValue("")
+			m.input.Focus()
+			m.pendingResumeID = m.sessionIDs[msg.cli]
+			m.status = helpRefine
+			m.adjustTextareaHeight()
+			return m, nil
+		}
+	}
+	if parseErr != nil {
+		if partial := recoverPartialOptions(parseText, m.sortBy); len(partial) > 0 {
+			return m.showRecovered(partial, "output was cut off")
+		}
+	}
+	if errors.Is(parseErr, errEmptyOptions) {
+		if m.emptyRetries < m.maxEmptyRetries {
+			return m.retryForOptions()
+		}
+		m.status = fmt.Sprintf("%s%s returned no options • r: regenerate • %s", truncNote, msg.cli, helpViewing)
+		m.options = nil
+		m.selected = 0
+		return m, nil
+	}
+	if parseErr != nil && m.parseRetries < m.maxParseRetries {
+		return m.retryForValidJSON()
+	}
 	if parseErr != nil {
 		m.lastParseError = parseErr
-		m.status = fmt.Sprintf("parse error: %v • %s", parseErr, helpViewing)
+		m.status = fmt.Sprintf("%sparse error: %v • %s • %s", truncNote, parseErr, parseErrorHint(parseErr), helpViewing)
 		m.options = nil
 		m.selected = 0
 		return m, nil
 	}
 
-	m.options = opts
-	m.selected = 0
+	m.showOptions(opts)
+	m.showInputHint = false
 	m.status = helpViewing
+	if hidden := len(opts) - len(m.options); hidden > 0 {
+		m.status = fmt.Sprintf("%d weaker options hidden by max-order %d • %s", hidden, m.maxOrder, helpViewing)
+	}
+	if len(blocks) > 1 {
+		m.optionBlocks = blocks
+		m.blockIndex = len(blocks) - 1
+		m.status = fmt.Sprintf("reply has %d options blocks, showing the last • b: cycle blocks", len(blocks))
+	}
+	if m.previousOptions != nil {
+		fresh := countNewOptions(m.previousOptions, m.options)
+		m.status = fmt.Sprintf("regenerated: %d new, %d repeated • d: toggle highlight", fresh, len(m.options)-fresh)
+	}
+	if msg.cached {
+		m.status = "cached response • r: regenerate for a fresh answer"
+	}
+	if msg.truncated {
+		m.status = strings.TrimSuffix(truncNote, " • ")
+	}
+	if msg.postprocessErr != nil {
+		// Keep what the status said about the options, e.g. a regenerate
+		// summary, after the warning.
+		m.status = fmt.Sprintf("%s postprocess failed, parsed raw output: %v • %s", icons.warn, msg.postprocessErr, m.status)
+	}
+
+	var store tea.Cmd
+	if msg.cacheKey != "" && m.cache != nil {
+		cache, key, output := m.cache, msg.cacheKey, msg.output
+		store = func() tea.Msg {
+			_ = cache.put(key, output)
+			return nil
+		}
+	}
 
-	if m.autoExecute && len(opts) > 0 {
-		value := opts[0].Value
-		m.status = fmt.Sprintf("running: %s", cleanText(value))
+	if m.autoExecute && len(m.options) > 0 {
 		m.autoExecute = false
-		return m, execWithFeedback(value, !m.stayOpenExec, m.stayOpenExec)
+		next, cmd := m.requestExec([]string{m.options[0].Value}, "")
+		return next, tea.Batch(cmd, store)
 	}
 
-	return m, nil
+	return m, store
 }
 
 func (m model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.showHelp {
+		if msg.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
+		m.showHelp = false
+		return m, nil
+	}
+	if isHelpKey(msg) || (m.mode == modeViewing && msg.String() == "?") {
+		m.showHelp = true
+		return m, nil
+	}
 	switch m.mode {
 	case modeInput:
 		return m.handleInputKeys(msg)
@@ -311,12 +744,29 @@ func (m model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleRunningKeys(msg)
 	case modeViewing:
 		return m.handleViewingKeys(msg)
+	case modePickCLI:
+		return m.handlePickerKeys(msg)
+	case modeNameFavorite:
+		return m.handleNameFavoriteKeys(msg)
+	case modePickFavorite:
+		return m.handleFavoritesKeys(msg)
 	default:
 		return m, nil
 	}
 }
 
+// doubleClickWindow is how close two clicks on an option must be to copy it.
+const doubleClickWindow = 400 * time.Millisecond
+
 func (m model) handleMouseMsg(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	if msg.Action == tea.MouseActionPress && (msg.Button == tea.MouseButtonWheelUp || msg.Button == tea.MouseButtonWheelDown) {
+		dir := 1
+		if msg.Button == tea.MouseButtonWheelUp {
+			dir = -1
+		}
+		m.handleWheel(dir)
+		return m, nil
+	}
 	if msg.Button != tea.MouseButtonLeft || msg.Action != tea.MouseActionPress {
 		return m, nil
 	}
@@ -331,7 +781,7 @@ func (m model) handleMouseMsg(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 		}
 		for _, reg := range layout.cliRegions {
 			if msg.X >= reg.startX && msg.X < reg.endX {
-				m.cliIndex = reg.index
+				m.selectCLI(reg.index)
 				m.status = currentHelp
 				return m, nil
 			}
@@ -344,7 +794,16 @@ func (m model) handleMouseMsg(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 
 	if m.mode == modeViewing || m.mode == modeRefine {
 		if idx := m.optionIndexAt(msg.Y); idx >= 0 {
+			// A second click on the same row, or a click on the selection
+			// arrow, copies like Enter.
+			onArrow := idx == m.selected && msg.X < 2
+			double := idx == m.lastClickIndex && time.Since(m.lastClickAt) < doubleClickWindow
 			m.selected = idx
+			m.lastClickIndex, m.lastClickAt = idx, time.Now()
+			if m.mode == modeViewing && m.pendingRun == nil && (onArrow || double) {
+				m.lastClickAt = time.Time{}
+				return m.copySelected()
+			}
 			return m, nil
 		}
 	}
@@ -352,14 +811,175 @@ func (m model) handleMouseMsg(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// handleWheel scrolls the raw or explanation view, or moves the selection.
+func (m *model) handleWheel(dir int) {
+	if m.mode != modeViewing || m.pendingRun != nil {
+		return
+	}
+	if m.explanation != "" || m.raw {
+		m.scrollRaw(dir)
+		return
+	}
+	m.setSelection(m.selected + dir)
+}
+
+// copyableValue is the selected value, or the raw reply when there are no
+// options.
+func (m model) copyableValue() string {
+	if value := m.selectedValue(); value != "" {
+		return value
+	}
+	return m.rawOutput
+}
+
+// writeSelection puts value on the clipboard, after the current contents in
+// -append mode, and returns the status to show.
+func (m model) writeSelection(value string) (string, error) {
+	return m.writeSelectionAs(value, value)
+}
+
+// writeSelectionAs is writeSelection naming the copy label in the status
+// instead of the value, for multi-line exports.
+func (m model) writeSelectionAs(value, label string) (string, error) {
+	if m.appendClipboard {
+		sep := m.appendSeparator
+		if sep == "" {
+			sep = defaultAppendSeparator
+		}
+		if err := appendValue(withCopyNewline(value, m.copyNewline), sep); err != nil {
+			return "", err
+		}
+		return m.afterCopy(fmt.Sprintf("%s Appended to clipboard: %s", icons.ok, label), value), nil
+	}
+	already, err := copyValue(withCopyNewline(value, m.copyNewline))
+	if err != nil {
+		return "", err
+	}
+	if already {
+		return m.afterCopy(fmt.Sprintf("%s Already on clipboard: %s", icons.ok, label), value), nil
+	}
+	return m.afterCopy(fmt.Sprintf("%s Copied to clipboard: %s", icons.ok, label), value), nil
+}
+
+// copyExport copies value, an export of the results (m, t, Y, D), the way
+// Enter copies a value: -append, copy_newline and on_copy all apply. The
+// results stay open.
+func (m model) copyExport(value, label string) (tea.Model, tea.Cmd) {
+	if len(m.options) == 0 {
+		m.status = "nothing to copy • " + helpViewing
+		return m, nil
+	}
+	status, err := m.writeSelectionAs(value, label)
+	if err != nil {
+		m.status = clipboardFailedStatus(err)
+		return m, nil
+	}
+	m.status = status
+	m.acted = true
+	return m, nil
+}
+
+// afterCopy fires the on_copy hook and notes a failure to start it in status.
+func (m model) afterCopy(status, value string) string {
+	if err := runCopyHook(m.onCopy, value); err != nil {
+		return fmt.Sprintf("%s • %s on_copy failed: %v", status, icons.warn, err)
+	}
+	return status
+}
+
+// yankSelected copies like Enter but keeps the results open, so several
+// values can be collected with -append.
+func (m model) yankSelected() (tea.Model, tea.Cmd) {
+	value := m.copyableValue()
+	if value == "" {
+		m.status = "nothing to copy • " + helpViewing
+		return m, nil
+	}
+	status, err := m.writeSelection(value)
+	if err != nil {
+		m.status = clipboardFailedStatus(err)
+		return m, nil
+	}
+	m.status = status
+	m.acted = true
+	m.recordAction("copied", value)
+	return m, nil
+}
+
+// copySelected copies the selected value (or the raw reply when there are no
+// options) and exits, or in daemon mode hands it to the waiting trigger.
+func (m model) copySelected() (tea.Model, tea.Cmd) {
+	value := m.copyableValue()
+	if value == "" {
+		m.status = "nothing to copy • " + helpViewing
+		return m, nil
+	}
+	status, err := m.writeSelection(value)
+	if err != nil {
+		m.status = clipboardFailedStatus(err)
+		return m, nil
+	}
+	m.status = status
+	m.acted = true
+	m.recordAction("copied", value)
+	if m.daemon {
+		m.replyTrigger(value)
+		status := m.status
+		m.resetForNewPrompt()
+		m.status = status
+		return m, nil
+	}
+	return m, tea.Quit
+}
+
 func (m model) handleInputKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	if msg.Type == tea.KeyCtrlC || msg.String() == "esc" {
+	// Bracketed paste delivers the whole clipboard as one message; send it
+	// straight to the textarea so pasted newlines never submit the prompt.
+	if msg.Paste {
+		return m.updateInput(msg)
+	}
+	if msg.Type == tea.KeyCtrlC {
 		return m, tea.Quit
 	}
+	if msg.String() == "esc" {
+		return m.dismiss()
+	}
 	if msg.Type == tea.KeyCtrlY || msg.String() == "ctrl+y" {
 		m.toggleYolo()
 		return m, nil
 	}
+	if msg.Type == tea.KeyCtrlO {
+		m.openCLIPicker()
+		return m, nil
+	}
+	if msg.Type == tea.KeyCtrlZ && m.mode == modeInput && m.stash != nil {
+		m.restoreResults()
+		return m, nil
+	}
+	if msg.Type == tea.KeyCtrlS && m.mode == modeInput {
+		m.startNamingFavorite()
+		return m, nil
+	}
+	if msg.Type == tea.KeyCtrlF && m.mode == modeInput {
+		m.openFavorites()
+		return m, nil
+	}
+	if msg.Type == tea.KeyCtrlG && m.mode == modeInput {
+		m.raw = !m.raw
+		if m.raw {
+			m.status = "raw mode on: the reply is shown as-is • ctrl+g: back to options"
+		} else {
+			m.status = helpInput
+		}
+		return m, nil
+	}
+	if msg.Type == tea.KeyCtrlX && m.mode == modeInput {
+		return m.startFanOut()
+	}
+	if msg.Type == tea.KeyCtrlL {
+		m.swapToLastCLI()
+		return m, nil
+	}
 	// ctrl-p = previous (left), ctrl-n = next (right)
 	if msg.Type == tea.KeyCtrlP {
 		m.prevCLI()
@@ -398,9 +1018,25 @@ func (m model) handleInputKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) handleViewingKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.Paste {
+		return m, nil
+	}
+	if m.pendingRun != nil {
+		return m.handleConfirmKeys(msg)
+	}
+	if m.explanation != "" {
+		return m.handleExplanationKeys(msg)
+	}
 	switch {
-	case msg.Type == tea.KeyCtrlC || msg.String() == "esc" || msg.String() == "q":
+	case msg.Type == tea.KeyCtrlC && m.execCancel != nil:
+		// Stop the running command but keep the results on screen.
+		m.execCancel()
+		m.status = "interrupting command..."
+		return m, nil
+	case msg.Type == tea.KeyCtrlC:
 		return m, tea.Quit
+	case msg.String() == "esc" || msg.String() == "q":
+		return m.dismiss()
 	case msg.Type == tea.KeyCtrlY || msg.String() == "ctrl+y":
 		m.toggleYolo()
 		return m, nil
@@ -420,77 +1056,578 @@ func (m model) handleViewingKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.pendingResumeID = sessionID
 		m.adjustTextareaHeight()
 		return m, nil
-	case msg.String() == "n":
-		m.mode = modeInput
-		m.running = false
-		m.input.SetValue("")
-		m.input.Focus()
-		m.status = helpInput
-		m.options = nil
-		m.lastParseError = nil
-		m.rawOutput = ""
-		m.lastPrompt = ""
-		m.autoExecute = false
+	case msg.String() == "r":
+		return m.regenerate()
+	case msg.String() == "+":
+		return m.askForMore()
+	case msg.String() == "y":
+		return m.yankSelected()
+	case msg.String() == "e":
+		return m.explainSelected()
+	case msg.String() == "d":
+		m.hideDiff = !m.hideDiff
+		return m, nil
+	case msg.String() == "v":
+		m.viewDetail = (m.viewDetail + 1) % viewDetailLevels
+		if m.viewDetail == detailOrder && m.hideOrder {
+			m.viewDetail++
+		}
+		m.status = fmt.Sprintf("view: %s • %s", m.viewDetail, helpViewing)
+		return m, nil
+	case msg.String() == "|":
+		if m.pipeCommand == "" {
+			m.status = "no pipe command configured (use -pipe) • " + helpViewing
+			return m, nil
+		}
+		value := m.selectedValue()
+		if value == "" {
+			m.status = "nothing to pipe • " + helpViewing
+			return m, nil
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		m.execCancel = cancel
+		m.status = fmt.Sprintf("piping to: %s", m.pipeCommand)
 		m.execOutput = ""
-		m.selected = 0
-		m.pendingResumeID = ""
-		m.promptHistory = nil
-		m.lastError = nil
-		m.adjustTextareaHeight()
+		return m, pipeWithFeedback(ctx, m.pipeCommand, value)
+	case msg.String() == "c":
+		m.openCLIPicker()
+		return m, nil
+	case msg.String() == "-":
+		m.swapToLastCLI()
+		return m, nil
+	case msg.String() == "s":
+		m.toggleSplitView()
+		return m, nil
+	case m.splitActive() && msg.String() == "J":
+		m.scrollSplit(1)
+		return m, nil
+	case m.splitActive() && msg.String() == "K":
+		m.scrollSplit(-1)
+		return m, nil
+	case msg.String() == "o":
+		value := strings.TrimSpace(m.selectedValue())
+		if !looksLikeURL(value) {
+			m.status = "selected value is not a URL • " + helpViewing
+			return m, nil
+		}
+		m.status = "opening " + value
+		return m, openURL(value)
+	case msg.String() == "p":
+		if strings.TrimSpace(m.lastPrompt) == "" {
+			m.status = "no prompt to copy • " + helpViewing
+			return m, nil
+		}
+		if err := writeClipboard(m.lastPrompt); err != nil {
+			m.status = clipboardFailedStatus(err)
+			return m, nil
+		}
+		m.status = fmt.Sprintf("%s Copied prompt to clipboard", icons.ok)
+		m.acted = true
+		return m, nil
+	case msg.String() == "i":
+		m.showArgv = !m.showArgv
+		return m, nil
+	case msg.String() == "m":
+		return m.copyExport(formatOptionsMarkdown(m.options), fmt.Sprintf("%d options as markdown", len(m.options)))
+	case msg.String() == "t":
+		return m.copyExport(formatOptionsTSV(m.options), fmt.Sprintf("%d options as TSV", len(m.options)))
+	case msg.String() == "Y":
+		return m.copyExport(formatOptionValues(m.options), fmt.Sprintf("%d values, one per line", len(m.options)))
+	case msg.String() == "D":
+		opt, ok := m.selectedOption()
+		if !ok {
+			m.status = "nothing to copy • " + helpViewing
+			return m, nil
+		}
+		value := formatOptionWithDescription(opt)
+		return m.copyExport(value, cleanText(value))
+	case msg.String() == "b":
+		m.cycleOptionBlock()
+		return m, nil
+	case msg.String() == "w":
+		m.toggleReasoning()
+		return m, nil
+	case msg.String() == "n":
+		m.resetForNewPrompt()
 		return m, nil
 	case isNewline(msg):
+		m.stashResults()
 		m.mode = modeInput
 		m.running = false
-		m.input.SetValue("")
+		m.input.SetValue(m.carriedPrompt())
 		m.input.Focus()
 		m.status = helpInput
 		m.options = nil
+		m.previousOptions = nil
+		m.marked = nil
 		m.lastParseError = nil
 		m.rawOutput = ""
 		m.autoExecute = false
 		m.execOutput = ""
+		m.adjustTextareaHeight()
+		return m, nil
+	case msg.Type == tea.KeySpace:
+		if len(m.options) == 0 {
+			return m, nil
+		}
+		if m.marked == nil {
+			m.marked = map[int]bool{}
+		}
+		if m.marked[m.selected] {
+			delete(m.marked, m.selected)
+		} else {
+			m.marked[m.selected] = true
+		}
+		m.status = fmt.Sprintf("%d marked • ctrl+r: run marked in order • space: toggle mark", len(m.marked))
 		return m, nil
 	case isCtrlR(msg):
-		value := m.selectedValue()
-		if value == "" {
-			if m.rawOutput == "" {
-				m.status = "nothing to run • " + helpViewing
+		if values := m.markedValues(); len(values) > 0 {
+			if m.execMode == execModeDirect {
+				m.status = fmt.Sprintf("%s running marked commands needs -exec-mode shell", icons.fail)
 				return m, nil
 			}
-			value = m.rawOutput
+			m.marked = nil
+			return m.requestExec(values, fmt.Sprintf("running %d marked commands", len(values)))
 		}
-		m.status = fmt.Sprintf("running: %s", cleanText(value))
-		m.execOutput = ""
-		return m, execWithFeedback(value, !m.stayOpenExec, m.stayOpenExec)
-	case msg.Type == tea.KeyEnter:
 		value := m.selectedValue()
 		if value == "" {
 			if m.rawOutput == "" {
-				m.status = "nothing to copy • " + helpViewing
+				m.status = "nothing to run • " + helpViewing
 				return m, nil
 			}
 			value = m.rawOutput
 		}
-		if err := clipboard.WriteAll(value); err != nil {
-			m.status = fmt.Sprintf("❌ CLIPBOARD FAILED: %v • Install xclip/xsel on Linux • %s", err, helpViewing)
+		return m.requestExec([]string{value}, "")
+	case msg.Type == tea.KeyEnter:
+		return m.copySelected()
+	case m.raw && (msg.String() == "up" || msg.String() == "k"):
+		m.scrollRaw(-1)
+	case m.raw && (msg.String() == "down" || msg.String() == "j"):
+		m.scrollRaw(1)
+	case msg.String() == "up" || msg.String() == "k":
+		m.moveSelection(-1)
+	case msg.String() == "down" || msg.String() == "j":
+		m.moveSelection(1)
+	case msg.Type == tea.KeyCtrlD:
+		m.setSelection(m.selected + m.halfPageStep(1))
+	case msg.Type == tea.KeyCtrlU:
+		m.setSelection(m.selected - m.halfPageStep(-1))
+	case msg.String() == "g" || msg.Type == tea.KeyHome:
+		m.setSelection(0)
+	case msg.String() == "G" || msg.Type == tea.KeyEnd:
+		m.setSelection(len(m.options) - 1)
+	}
+	return m, nil
+}
+
+func (m *model) openCLIPicker() {
+	m.pickerReturn = m.mode
+	m.pickerIndex = m.cliIndex
+	m.mode = modePickCLI
+	m.input.Blur()
+	m.status = helpPicker
+}
+
+func (m *model) closeCLIPicker() {
+	m.mode = m.pickerReturn
+	switch m.mode {
+	case modeInput:
+		m.input.Focus()
+		m.status = helpInput
+	case modeRefine:
+		m.input.Focus()
+		m.status = helpRefine
+	default:
+		m.status = helpViewing
+	}
+}
+
+// showRawResponse displays the whole reply for -raw mode; copy and run then
+// act on all of it.
+func (m model) showRawResponse(text string, msg responseMsg) (tea.Model, tea.Cmd) {
+	m.rawOutput = strings.TrimSpace(text)
+	m.options = nil
+	m.selected = 0
+	m.rawScroll = 0
+	m.showInputHint = false
+	m.status = helpRaw
+	if msg.cached {
+		m.status = "cached response • " + helpRaw
+	}
+	if msg.cacheKey == "" || m.cache == nil {
+		return m, nil
+	}
+	cache, key, output := m.cache, msg.cacheKey, msg.output
+	return m, func() tea.Msg {
+		_ = cache.put(key, output)
+		return nil
+	}
+}
+
+func (m model) rawViewHeight() int {
+	return max(5, m.height-8)
+}
+
+// scrollLines is the text shown in the scrollable view: an option
+// explanation (wrapped to the window) when open, else the raw reply. With
+// -markdown the explanation follows the option's description and both are
+// rendered as markdown.
+func (m model) scrollLines() []string {
+	if m.explanation != "" && m.markdown {
+		md := m.explanation
+		if opt, ok := m.selectedOption(); ok && strings.TrimSpace(opt.Description) != "" {
+			md = opt.Description + "\n\n---\n\n" + md
+		}
+		return strings.Split(renderMarkdown(md, m.width-4, m.noColor), "\n")
+	}
+	if m.explanation != "" {
+		wrapped := lipgloss.NewStyle().Width(max(20, m.width-4)).Render(m.explanation)
+		return strings.Split(wrapped, "\n")
+	}
+	return strings.Split(m.rawOutput, "\n")
+}
+
+func (m *model) scrollRaw(delta int) {
+	lines := len(m.scrollLines())
+	m.rawScroll = max(0, min(m.rawScroll+delta, lines-m.rawViewHeight()))
+}
+
+func (m model) renderRawOutput() string {
+	lines := m.scrollLines()
+	end := min(len(lines), m.rawScroll+m.rawViewHeight())
+	textStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("15"))
+	var b strings.Builder
+	b.WriteString(textStyle.Render(strings.Join(lines[m.rawScroll:end], "\n")))
+	b.WriteString("\n")
+	if len(lines) > m.rawViewHeight() {
+		posStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(grayColor))
+		b.WriteString(posStyle.Render(fmt.Sprintf("lines %d-%d of %d", m.rawScroll+1, end, len(lines))))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// explainMsg carries the CLI's explanation of one option.
+type explainMsg struct {
+	value  string
+	output []byte
+	err    error
+}
+
+func explainPrompt(userPrompt string, opt optionEntry) string {
+	var b strings.Builder
+	b.WriteString("Explain the following suggestion in plain text (no JSON): what it does, each part, and any caveats. Keep it short.\n")
+	if userPrompt != "" {
+		fmt.Fprintf(&b, "Original request: %s\n", userPrompt)
+	}
+	fmt.Fprintf(&b, "Suggestion: %s\n", opt.Value)
+	if opt.Description != "" {
+		fmt.Fprintf(&b, "Its description: %s\n", opt.Description)
+	}
+	return b.String()
+}
+
+// explainSelected asks the current CLI to expand on the selected option and
+// shows the answer in a detail view over the results.
+func (m model) explainSelected() (tea.Model, tea.Cmd) {
+	if m.selected < 0 || m.selected >= len(m.options) {
+		m.status = "nothing to explain • " + helpViewing
+		return m, nil
+	}
+	opt := m.options[m.selected]
+	cli := m.currentCLI()
+	run := cli.runRaw
+	if run == nil {
+		run = cli.runPrompt
+	}
+	prompt := explainPrompt(m.lastPrompt, opt)
+	yolo, maxOutput := m.yolo, m.maxOutputBytes
+	timeout := cliTimeout(cli.name, m.timeout, m.timeouts)
+	m.status = fmt.Sprintf("%s asking %s to explain: %s", icons.loading, cli.name, cleanText(opt.Value))
+	return m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		out, _, err := cli.capture(run(ctx, prompt, yolo), maxOutput)
+		return explainMsg{value: opt.Value, output: out, err: err}
+	}
+}
+
+func (m model) handleExplainMsg(msg explainMsg) (tea.Model, tea.Cmd) {
+	// Drop answers for an option that is no longer on screen.
+	if m.mode != modeViewing || m.selectedValue() != msg.value {
+		return m, nil
+	}
+	if msg.err != nil {
+		m.status = fmt.Sprintf("%s explain failed: %v • %s", icons.fail, msg.err, helpViewing)
+		return m, nil
+	}
+	text := strings.TrimSpace(replyText(string(msg.output)))
+	if text == "" {
+		m.status = "no explanation returned • " + helpViewing
+		return m, nil
+	}
+	m.explanation = text
+	m.rawScroll = 0
+	m.status = helpExplain
+	return m, nil
+}
+
+func (m model) handleExplanationKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Type == tea.KeyCtrlC:
+		return m, tea.Quit
+	case msg.String() == "esc" || msg.String() == "q" || msg.String() == "e" || msg.Type == tea.KeyBackspace:
+		m.explanation = ""
+		m.rawScroll = 0
+		m.status = helpViewing
+	case msg.String() == "up" || msg.String() == "k":
+		m.scrollRaw(-1)
+	case msg.String() == "down" || msg.String() == "j":
+		m.scrollRaw(1)
+	case msg.Type == tea.KeyEnter:
+		if err := writeClipboard(m.explanation); err != nil {
+			m.status = clipboardFailedStatus(err)
 			return m, nil
 		}
-		m.status = fmt.Sprintf("✅ Copied to clipboard: %s", value)
+		m.status = icons.ok + " Copied explanation • " + helpExplain
+		m.acted = true
+	}
+	return m, nil
+}
+
+// runInfo describes the last CLI run for the results footer.
+type runInfo struct {
+	cli     string
+	elapsed time.Duration
+	cached  bool
+}
+
+// optionCount is "1 option" or "n options".
+func optionCount(n int) string {
+	if n == 1 {
+		return "1 option"
+	}
+	return fmt.Sprintf("%d options", n)
+}
+
+func (m model) renderRunFooter() string {
+	if m.lastRun.cli == "" {
+		return ""
+	}
+	latency := m.lastRun.elapsed.Round(100 * time.Millisecond).String()
+	if m.lastRun.cached {
+		latency = "cached"
+	}
+	count := optionCount(len(m.options))
+	if m.raw {
+		count = "raw"
+	}
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color(grayColor))
+	return style.Render(strings.Join([]string{m.lastRun.cli, latency, count}, " • "))
+}
+
+// resetForNewPrompt clears the results and returns to an empty input.
+func (m *model) resetForNewPrompt() {
+	m.stashResults()
+	m.mode = modeInput
+	m.running = false
+	m.input.SetValue(m.carriedPrompt())
+	m.input.Focus()
+	m.status = helpInput
+	m.options = nil
+	m.previousOptions = nil
+	m.marked = nil
+	m.pendingRun = nil
+	m.blockedRun = ""
+	m.lastParseError = nil
+	m.rawOutput = ""
+	m.lastPrompt = ""
+	m.lastRun = runInfo{}
+	m.autoExecute = false
+	m.execOutput = ""
+	m.selected = 0
+	m.pendingResumeID = ""
+	m.promptHistory = nil
+	m.clarifying = ""
+	m.explanation = ""
+	if m.session {
+		m.sessionIDs = map[string]string{}
+	}
+	m.lastError = nil
+	m.adjustTextareaHeight()
+}
+
+// carriedPrompt is what a new prompt starts with: empty, or with
+// -sticky-prompt the prompt just sent, ready to tweak and resend.
+func (m model) carriedPrompt() string {
+	if !m.stickyPrompt {
+		return ""
+	}
+	return m.lastPrompt
+}
+
+// resultsStash is the results view as it was before starting a new prompt,
+// so ctrl+z can bring it back until another prompt is sent.
+type resultsStash struct {
+	options       []optionEntry
+	selected      int
+	marked        map[int]bool
+	rawOutput     string
+	lastPrompt    string
+	lastRun       runInfo
+	promptHistory []string
+}
+
+func (m *model) stashResults() {
+	if m.mode != modeViewing || (len(m.options) == 0 && m.rawOutput == "") {
+		return
+	}
+	m.stash = &resultsStash{
+		options:       m.options,
+		selected:      m.selected,
+		marked:        m.marked,
+		rawOutput:     m.rawOutput,
+		lastPrompt:    m.lastPrompt,
+		lastRun:       m.lastRun,
+		promptHistory: m.promptHistory,
+	}
+}
+
+// restoreResults reopens the stashed results, keeping whatever was typed.
+func (m *model) restoreResults() {
+	s := m.stash
+	m.stash = nil
+	m.options = s.options
+	m.selected = s.selected
+	m.marked = s.marked
+	m.rawOutput = s.rawOutput
+	m.lastPrompt = s.lastPrompt
+	m.lastRun = s.lastRun
+	m.promptHistory = s.promptHistory
+	m.lastParseError = nil
+	m.lastError = nil
+	m.mode = modeViewing
+	m.input.Blur()
+	m.status = "restored previous results • " + helpViewing
+	m.adjustTextareaHeight()
+}
+
+func (m model) handlePickerKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Type == tea.KeyCtrlC:
 		return m, tea.Quit
+	case msg.String() == "esc" || msg.String() == "q":
+		m.closeCLIPicker()
 	case msg.String() == "up" || msg.String() == "k":
-		m.moveSelection(-1)
+		m.pickerIndex = (m.pickerIndex - 1 + len(m.cliOptions)) % len(m.cliOptions)
 	case msg.String() == "down" || msg.String() == "j":
-		m.moveSelection(1)
+		m.pickerIndex = (m.pickerIndex + 1) % len(m.cliOptions)
+	case msg.Type == tea.KeyEnter:
+		m.selectCLI(m.pickerIndex)
+		m.closeCLIPicker()
+	}
+	return m, nil
+}
+
+func (m model) renderCLIPicker() string {
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("205")).
+		Padding(0, 1)
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Bold(true)
+	selectedStyle := lipgloss.NewStyle().
+		Background(lipgloss.Color("62")).
+		Foreground(lipgloss.Color("230")).
+		Bold(true)
+	normalStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("15"))
+	currentStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(grayColor))
+
+	rows := []string{titleStyle.Render("Select CLI")}
+	for i, opt := range m.cliOptions {
+		line := "  " + opt.name
+		if i == m.pickerIndex {
+			line = selectedStyle.Render("▶ " + opt.name)
+		} else {
+			line = normalStyle.Render(line)
+		}
+		if i == m.cliIndex {
+			line += currentStyle.Render("  (current)")
+		}
+		rows = append(rows, line)
+	}
+	return boxStyle.Render(strings.Join(rows, "\n")) + "\n"
+}
+
+func (m model) handleRunningKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.Type == tea.KeyCtrlC {
+		return m, tea.Quit
+	}
+	if msg.String() == "esc" {
+		return m.cancelRun()
+	}
+	if msg.Type == tea.KeyTab {
+		return m.rerunOnNextCLI()
+	}
+	return m, nil
+}
+
+// cancelRun abandons the request in flight and goes back to the prompt with
+// the text that was sent, ready to edit and resend.
+func (m model) cancelRun() (tea.Model, tea.Cmd) {
+	m.abandonRun()
+	if m.daemon {
+		// Like dismiss: the waiting -trigger client gets an empty reply.
+		cli := m.currentCLI().name
+		m.replyTrigger("")
+		m.resetForNewPrompt()
+		m.status = fmt.Sprintf("cancelled %s • %s", cli, helpInput)
+		return m, nil
 	}
+	m.mode = modeInput
+	m.input.SetValue(m.lastPrompt)
+	m.input.Focus()
+	m.adjustTextareaHeight()
+	m.status = fmt.Sprintf("cancelled %s • %s", m.currentCLI().name, helpInput)
 	return m, nil
 }
 
-func (m model) handleRunningKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	// Only allow quitting while running
-	if msg.Type == tea.KeyCtrlC || msg.String() == "esc" {
-		return m, tea.Quit
+// abandonRun stops the CLI request in flight, if any, and bumps runGen so its
+// reply is dropped should it still arrive.
+func (m *model) abandonRun() {
+	if m.runCancel != nil {
+		m.runCancel()
+		m.runCancel = nil
 	}
-	return m, nil
+	m.runGen++
+	m.running = false
+	m.fanOut = nil
+}
+
+// runningHint lists what can be done during a run, so it's discoverable.
+func (m model) runningHint() string {
+	hint := "esc: cancel"
+	if len(m.cliOptions) > 1 {
+		next := m.cliOptions[(m.cliIndex+1)%len(m.cliOptions)].name
+		hint += " • tab: switch to " + next
+	}
+	return hint + " • ctrl+c: quit"
+}
+
+// rerunOnNextCLI abandons the request in flight and sends the same prompt to
+// the next CLI. A resumed session can't move to another CLI, so the original
+// prompt is sent fresh instead.
+func (m model) rerunOnNextCLI() (tea.Model, tea.Cmd) {
+	m.abandonRun()
+	from := m.currentCLI().name
+	m.nextCLI()
+	prompt := m.lastDispatch
+	if m.lastDispatchSession != "" {
+		prompt = m.lastPrompt
+	}
+	next, cmd := m.dispatchPrompt(prompt, "", true)
+	nm := next.(model)
+	nm.status = fmt.Sprintf("cancelled %s • trying %s", from, nm.currentCLI().name)
+	return nm, cmd
 }
 
 func (m *model) toggleYolo() {
@@ -528,13 +1665,56 @@ func (m *model) adjustTextareaHeight() {
 	}
 }
 
-func (m model) optionIndexAt(y int) int {
+func isHelpStatus(status string) bool {
+	switch status {
+	case helpInput, helpViewing, helpRefine, helpPicker, helpRaw:
+		return true
+	}
+	return false
+}
+
+func clipboardFailedStatus(err error) string {
+	return fmt.Sprintf("%s CLIPBOARD FAILED: %v • Install xclip/xsel on Linux • %s", icons.fail, err, helpViewing)
+}
+
+// optionsTop returns the screen row where the first option is rendered.
+func (m model) optionsTop() int {
 	row := 1 // header occupies row 0
 	if len(m.promptHistory) > 0 {
 		row += len(m.promptHistory)
 	} else if strings.TrimSpace(m.lastPrompt) != "" {
 		row++
 	}
+	return row
+}
+
+// halfPageStep returns how many options fit in half of the rows available to
+// the option list, walking from the selection in direction dir.
+func (m model) halfPageStep(dir int) int {
+	// Reserve the divider and status line below the list.
+	available := m.height - m.optionsTop() - 2
+	budget := available / 2
+	step := 0
+	used := 0
+	for i := m.selected + dir; i >= 0 && i < len(m.options); i += dir {
+		used += len(m.optionLines(m.options[i], i, false, false).lines)
+		if used > budget && step > 0 {
+			break
+		}
+		step++
+	}
+	if step < 1 {
+		step = 1
+	}
+	return step
+}
+
+func (m model) optionIndexAt(y int) int {
+	row := m.optionsTop()
+	if m.splitActive() {
+		// Rows wrap to the left pane, as renderSplitView draws them.
+		m.width, _ = m.splitWidths()
+	}
 
 	if m.lastError != nil || m.lastParseError != nil || len(m.options) == 0 {
 		return -1
@@ -542,7 +1722,7 @@ func (m model) optionIndexAt(y int) int {
 
 	currentRow := row
 	for idx, opt := range m.options {
-		lines := m.optionLines(opt, false)
+		lines := m.optionLines(opt, idx, false, false)
 		if y >= currentRow && y < currentRow+len(lines.lines) {
 			return idx
 		}
@@ -628,7 +1808,27 @@ func wrapWithStarts(text string, width int) wrappedText {
 	return wrappedText{lines: lines, starts: starts}
 }
 
-func (m model) optionLines(opt optionEntry, selected bool) optionRenderLines {
+// viewDetail is how much of each option the list shows; v cycles it.
+type viewDetail int
+
+const (
+	detailDescriptions viewDetail = iota // value and description
+	detailOrder                          // plus recommendation_order
+	detailValues                         // values only
+	viewDetailLevels
+)
+
+func (d viewDetail) String() string {
+	switch d {
+	case detailOrder:
+		return "values, descriptions and order"
+	case detailValues:
+		return "values only"
+	}
+	return "values and descriptions"
+}
+
+func (m model) optionLines(opt optionEntry, index int, selected, marked bool) optionRenderLines {
 	totalWidth := m.width
 	if totalWidth < 30 {
 		totalWidth = 30
@@ -636,6 +1836,17 @@ func (m model) optionLines(opt optionEntry, selected bool) optionRenderLines {
 
 	prefixSelected := "▶ "
 	prefixNormal := "  "
+	if marked {
+		// Same width as the unmarked prefixes so wrapping is unaffected.
+		prefixSelected = "▶●"
+		prefixNormal = " ●"
+	}
+	if m.numbered {
+		// Pad to the widest number so values stay aligned.
+		label := fmt.Sprintf("%*d. ", len(strconv.Itoa(len(m.options))), index+1)
+		prefixSelected += label
+		prefixNormal += label
+	}
 	prefixWidth := runewidth.StringWidth(prefixSelected)
 	if pw := runewidth.StringWidth(prefixNormal); pw > prefixWidth {
 		prefixWidth = pw
@@ -646,7 +1857,25 @@ func (m model) optionLines(opt optionEntry, selected bool) optionRenderLines {
 	}
 
 	value := cleanText(opt.Value)
+	if m.maxValueWidth > 0 {
+		// Display only; copy/exec still use opt.Value.
+		value = runewidth.Truncate(value, m.maxValueWidth, "…")
+	}
+	if m.autoSelect && index == m.suggested {
+		value += "  " + icons.suggest + " suggested"
+	}
 	desc := strings.TrimSpace(cleanText(opt.Description))
+	switch m.viewDetail {
+	case detailValues:
+		desc = ""
+	case detailOrder:
+		if opt.RecommendationOrder > 0 && !m.hideOrder {
+			desc = strings.TrimSpace(fmt.Sprintf("%s (order %d)", desc, opt.RecommendationOrder))
+		}
+	}
+	if extra := formatExtraFields(opt.Extra, m.extraFields); extra != "" && m.viewDetail != detailValues {
+		desc = strings.TrimSpace(fmt.Sprintf("%s [%s]", desc, extra))
+	}
 
 	combined := value
 	commentStart := -1
@@ -695,6 +1924,10 @@ func (m model) optionLines(opt optionEntry, selected bool) optionRenderLines {
 			highlight: selected && strings.TrimSpace(valueText) != "",
 		})
 	}
+	if m.present {
+		// Spacer row; part of the option so click hit-testing stays aligned.
+		lines = append(lines, optionRenderLine{})
+	}
 
 	return optionRenderLines{lines: lines}
 }
@@ -732,6 +1965,8 @@ func (m model) submitPrompt() (tea.Model, tea.Cmd) {
 	}
 
 	wasRefine := m.mode == modeRefine
+	question := m.clarifying
+	m.clarifying = ""
 	if wasRefine && len(m.promptHistory) > 0 {
 		m.promptHistory = append(m.promptHistory, userPrompt)
 	} else {
@@ -741,55 +1976,310 @@ func (m model) submitPrompt() (tea.Model, tea.Cmd) {
 	m.lastPrompt = userPrompt
 	combinedPrompt := strings.Join(m.promptHistory, "\n")
 	promptContent := combinedPrompt
+	sessionID := ""
 	if wasRefine {
 		// For resume flows, only send the new prompt; the session carries prior context.
 		promptContent = userPrompt
+		sessionID = m.pendingResumeID
+		if question != "" && sessionID == "" {
+			// Nothing to resume, so resend the exchange for context.
+			earlier := strings.Join(m.promptHistory[:len(m.promptHistory)-1], "\n")
+			promptContent = earlier + "\nYou asked: " + question + "\nMy answer: " + userPrompt
+		}
+	} else if id := m.sessionIDs[m.currentCLI().name]; m.session && id != "" {
+		// With -session, new prompts continue the CLI's last session too.
+		promptContent = userPrompt
+		sessionID = id
+	}
+	m.previousOptions = nil
+	return m.dispatchPrompt(promptContent, sessionID, sessionID == "")
+}
+
+// regenerate re-sends the current prompt history in a fresh session, keeping
+// the old options around so new ones can be highlighted.
+func (m model) regenerate() (tea.Model, tea.Cmd) {
+	if len(m.promptHistory) == 0 {
+		m.status = "nothing to regenerate • " + helpViewing
+		return m, nil
+	}
+	m.previousOptions = m.options
+	m.autoExecute = false
+	return m.dispatchPrompt(strings.Join(m.promptHistory, "\n"), "", false)
+}
+
+// minAlternatives is the fewest options "+" asks for.
+const minAlternatives = 5
+
+// moreAlternativesPrompt re-asks prompt for a wider spread of options than
+// the ones already shown.
+func moreAlternativesPrompt(prompt string, shown []optionEntry) string {
+	want := len(shown) + 3
+	if want < minAlternatives {
+		want = minAlternatives
+	}
+	var b strings.Builder
+	b.WriteString(prompt)
+	fmt.Fprintf(&b, "\nGive at least %d distinct alternatives, covering different approaches or tools.", want)
+	if len(shown) > 0 {
+		values := make([]string, len(shown))
+		for i, opt := range shown {
+			values[i] = opt.Value
+		}
+		fmt.Fprintf(&b, " Go beyond these earlier suggestions: %s", strings.Join(values, " | "))
+	}
+	return b.String()
+}
+
+// askForMore is regenerate with an instruction to return more, and more
+// varied, options, for when the first answer was too narrow.
+func (m model) askForMore() (tea.Model, tea.Cmd) {
+	if len(m.promptHistory) == 0 {
+		m.status = "nothing to expand • " + helpViewing
+		return m, nil
+	}
+	shown := m.options
+	m.previousOptions = shown
+	m.autoExecute = false
+	return m.dispatchPrompt(moreAlternativesPrompt(strings.Join(m.promptHistory, "\n"), shown), "", false)
+}
+
+// showOptions filters opts for display and selects the first one, or with
+// -auto-select the top recommendation.
+func (m *model) showOptions(opts []optionEntry) {
+	m.options = filterByMaxOrder(opts, m.maxOrder, m.dropUnordered)
+	if m.style == styleAnswer && len(m.options) > 1 {
+		// Keep the best answer only, even if the CLI offered more.
+		m.options = m.options[:1]
+	}
+	m.selected = 0
+	m.suggested = -1
+	if m.autoSelect {
+		m.suggested = recommendedIndex(m.options)
+		m.setSelection(m.suggested)
+	}
+}
+
+// recommendedIndex is the index of the option with the lowest
+// recommendation_order, which need not be the first with sort_by by-index.
+// Without any order it is the first option; -1 when there are none.
+func recommendedIndex(opts []optionEntry) int {
+	if len(opts) == 0 {
+		return -1
+	}
+	best := 0
+	for i, opt := range opts {
+		order := opt.RecommendationOrder
+		if order > 0 && (opts[best].RecommendationOrder <= 0 || order < opts[best].RecommendationOrder) {
+			best = i
+		}
+	}
+	return best
+}
+
+// cycleOptionBlock shows the next options block of a reply that had several.
+func (m *model) cycleOptionBlock() {
+	if len(m.optionBlocks) < 2 {
+		m.status = "the reply has only one options block • " + helpViewing
+		return
+	}
+	m.blockIndex = (m.blockIndex + 1) % len(m.optionBlocks)
+	m.showOptions(m.optionBlocks[m.blockIndex])
+	m.marked = nil
+	m.explanation = ""
+	m.status = fmt.Sprintf("options block %d/%d • b: next block", m.blockIndex+1, len(m.optionBlocks))
+}
+
+// showRecovered shows options salvaged from cut-off output. They are never
+// cached or auto-run, since the rest of the reply is missing.
+func (m model) showRecovered(opts []optionEntry, cause string) (tea.Model, tea.Cmd) {
+	m.options = filterByMaxOrder(opts, m.maxOrder, m.dropUnordered)
+	m.selected = 0
+	m.suggested = -1
+	m.autoExecute = false
+	m.status = fmt.Sprintf("%s %s • recovered %s from partial output • %s", icons.warn, cause, optionCount(len(m.options)), helpViewing)
+	return m, nil
+}
+
+// retryForValidJSON re-sends the last prompt with a reminder to answer with
+// JSON only, after the CLI replied with something unparseable.
+func (m model) retryForValidJSON() (tea.Model, tea.Cmd) {
+	nm, cmd := m.redispatch(jsonRetryInstruction)
+	nm.parseRetries++
+	nm.status = fmt.Sprintf("retrying for valid JSON (%d/%d)", nm.parseRetries, m.maxParseRetries)
+	return nm, cmd
+}
+
+// retryForOptions re-sends the prompt after a reply with an empty options
+// array, asking for at least one.
+func (m model) retryForOptions() (tea.Model, tea.Cmd) {
+	nm, cmd := m.redispatch(emptyRetryInstruction)
+	nm.emptyRetries++
+	nm.status = fmt.Sprintf("no options returned, asking again (%d/%d)", nm.emptyRetries, m.maxEmptyRetries)
+	return nm, cmd
+}
+
+// redispatch re-sends lastDispatch with instruction appended, keeping the
+// retry counters that dispatchPrompt would reset.
+func (m model) redispatch(instruction string) (model, tea.Cmd) {
+	next, cmd := m.dispatchPrompt(m.lastDispatch+"\n\n"+instruction, m.lastDispatchSession, false)
+	nm := next.(model)
+	nm.lastDispatch = m.lastDispatch
+	nm.parseRetries, nm.emptyRetries = m.parseRetries, m.emptyRetries
+	return nm, cmd
+}
+
+// dispatchPrompt sends promptContent to the current CLI, resuming sessionID
+// when set. Fresh prompts are cached; useCache allows answering from it.
+func (m model) dispatchPrompt(promptContent, sessionID string, useCache bool) (tea.Model, tea.Cmd) {
+	selectedCLI := m.currentCLI()
+	cliName := selectedCLI.name
+	postprocess := m.postprocess
+	maxOutput := m.maxOutputBytes
+	m.lastDispatch = promptContent
+	m.lastDispatchSession = sessionID
+	m.parseRetries, m.emptyRetries = 0, 0
+	m.stash = nil
+	// A resumed session already has the prefix and attachments.
+	var attachments []attachment
+	if sessionID == "" {
+		promptContent = applyPromptPrefix(m.promptPrefix, promptContent)
+		attachments = m.attachments
+	}
+	fullPrompt := buildPrompt(cliName, m.style, promptContent, m.promptTemplates, m.language, attachments)
+	raw := m.raw
+	if raw {
+		fullPrompt = appendAttachments(promptContent, attachments)
+		sessionID = ""
+	}
+	sizeWarning, err := checkPromptLength(cliName, fullPrompt, promptLimitFor(cliName, m.promptLimit, m.promptLimits))
+	if err != nil {
+		m.status = fmt.Sprintf("%s %v", icons.fail, err)
+		return m, nil
+	}
+	m.lastFullPrompt = fullPrompt
+	cache := m.cache
+	key := ""
+	if sessionID == "" && cache != nil {
+		cacheCLI := cliName
+		if raw {
+			cacheCLI += "/raw"
+		}
+		key = cacheKey(cacheCLI, fullPrompt, m.yolo)
 	}
-	fullPrompt := buildPrompt(promptContent)
 	m.running = true
 	m.mode = modeRunning
 	m.spinnerFrame = 0
 	m.status = ""
+	if sizeWarning != "" {
+		m.status = icons.warn + " " + sizeWarning
+	}
 	m.options = nil
 	m.lastParseError = nil
 	m.lastError = nil
 	m.rawOutput = ""
 	m.execOutput = ""
 	m.selected = 0
-	sessionID := ""
-	if wasRefine {
-		sessionID = m.pendingResumeID
-	}
 	m.pendingResumeID = ""
 
-	selectedCLI := m.currentCLI()
-	cliName := selectedCLI.name
-	cmd := func() tea.Msg {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), cliTimeout(cliName, m.timeout, m.timeouts))
+	m.runCancel = cancel
+	gen := m.runGen
+	cmd := func() (msg tea.Msg) {
+		defer recoverAsResponse(cliName, gen, &msg)
 		defer cancel()
-		runPrompt := selectedCLI.runPrompt
-		if sessionID != "" && selectedCLI.resumePrompt != nil {
-			runPrompt = func(ctx context.Context, prompt string, yolo bool) ([]byte, error) {
-				return selectedCLI.resumePrompt(ctx, prompt, sessionID, yolo)
+		var cached []byte
+		hit := false
+		if useCache && key != "" {
+			cached, hit = cache.get(key)
+		}
+		var resp responseMsg
+		if hit {
+			resp = responseMsg{output: cached, cli: cliName, cached: true}
+		} else {
+			var c *exec.Cmd
+			if raw && selectedCLI.runRaw != nil {
+				c = selectedCLI.runRaw(ctx, fullPrompt, m.yolo)
+			} else if sessionID != "" && selectedCLI.resumePrompt != nil {
+				c = selectedCLI.resumePrompt(ctx, fullPrompt, sessionID, m.yolo)
+			} else {
+				c = selectedCLI.runPrompt(ctx, fullPrompt, m.yolo)
+			}
+			start := time.Now()
+			out, truncated, err := selectedCLI.capture(c, maxOutput)
+			if err != nil && ctx.Err() != nil {
+				// Report the timeout or cancellation, not the kill it caused.
+				err = ctx.Err()
+			}
+			resp = responseMsg{
+				output:    out,
+				err:       err,
+				cli:       cliName,
+				argv:      c.Args,
+				truncated: truncated,
+				elapsed:   time.Since(start),
+			}
+			if !truncated {
+				resp.cacheKey = key
 			}
 		}
-		out, err := runPrompt(ctx, fullPrompt, m.yolo)
-		return responseMsg{
-			output: out,
-			err:    err,
-			cli:    cliName,
+		if resp.err == nil && postprocess != "" {
+			resp.processed, resp.postprocessErr = postprocessOutput(ctx, postprocess, resp.output)
 		}
+		resp.gen = gen
+		return resp
 	}
 
 	m.resizeComponents()
-	return m, tea.Batch(cmd, tickCmd)
+	return m, tea.Batch(cmd, m.startTicking())
+}
+
+// parseErrorHint suggests what to do about a reply without options.
+func parseErrorHint(err error) string {
+	if errors.Is(err, errNoJSON) {
+		return "the reply was plain text; n, then ctrl+g for raw mode"
+	}
+	return "r: regenerate"
+}
+
+// runFailure describes a failed run of cliName, naming a timeout or a
+// cancellation rather than showing Go's context error text.
+func (m model) runFailure(cliName string, err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Sprintf("%s timed out after %s", cliName, cliTimeout(cliName, m.timeout, m.timeouts))
+	case errors.Is(err, context.Canceled):
+		return fmt.Sprintf("%s run cancelled", cliName)
+	}
+	return fmt.Sprintf("error from %s: %v", cliName, err)
+}
+
+// recoverAsResponse turns a panic in a CLI closure into an error response so
+// the UI reports it instead of the whole program crashing. Use it deferred.
+func recoverAsResponse(cliName string, gen int, msg *tea.Msg) {
+	if r := recover(); r != nil {
+		*msg = responseMsg{cli: cliName, gen: gen, err: fmt.Errorf("internal error: %v", r)}
+	}
+}
+
+// startTicking schedules the next spinner frame unless one is already pending,
+// so focus changes never start a second tick loop.
+func (m *model) startTicking() tea.Cmd {
+	if m.ticking {
+		return nil
+	}
+	m.ticking = true
+	interval := m.tickInterval
+	if interval <= 0 {
+		interval = defaultTickInterval
+	}
+	return tickCmd(interval)
 }
 
 func (m *model) nextCLI() {
 	if len(m.cliOptions) == 0 {
 		return
 	}
-	m.cliIndex = (m.cliIndex + 1) % len(m.cliOptions)
+	m.selectCLI((m.cliIndex + 1) % len(m.cliOptions))
 	m.status = helpInput
 }
 
@@ -797,10 +2287,32 @@ func (m *model) prevCLI() {
 	if len(m.cliOptions) == 0 {
 		return
 	}
-	m.cliIndex = (m.cliIndex - 1 + len(m.cliOptions)) % len(m.cliOptions)
+	m.selectCLI((m.cliIndex - 1 + len(m.cliOptions)) % len(m.cliOptions))
 	m.status = helpInput
 }
 
+// selectCLI switches to the CLI at idx, remembering the one it leaves.
+func (m *model) selectCLI(idx int) {
+	if idx == m.cliIndex {
+		return
+	}
+	m.lastCLI = m.currentCLI().name
+	m.cliIndex = idx
+}
+
+// swapToLastCLI goes back to the previously selected CLI, undoing an
+// overshoot with ctrl+n/ctrl+p. Pressing it again swaps back.
+func (m *model) swapToLastCLI() {
+	for i, opt := range m.cliOptions {
+		if opt.name == m.lastCLI && i != m.cliIndex {
+			m.selectCLI(i)
+			m.status = fmt.Sprintf("back to %s", m.currentCLI().name)
+			return
+		}
+	}
+	m.status = "no previous CLI to go back to"
+}
+
 func (m model) currentCLI() cliOption {
 	return m.cliOptions[m.cliIndex]
 }
@@ -840,14 +2352,43 @@ func (m *model) moveSelection(delta int) {
 	m.selected = (m.selected + delta + len(m.options)) % len(m.options)
 }
 
-func (m model) selectedValue() string {
+// setSelection moves to idx, clamped to the option list (no wraparound).
+func (m *model) setSelection(idx int) {
 	if len(m.options) == 0 {
-		return ""
+		return
+	}
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(m.options) {
+		idx = len(m.options) - 1
+	}
+	m.selected = idx
+}
+
+// markedValues returns the values of marked options in display order.
+func (m model) markedValues() []string {
+	var values []string
+	for i, opt := range m.options {
+		if m.marked[i] {
+			values = append(values, opt.Value)
+		}
 	}
+	return values
+}
+
+func (m model) selectedValue() string {
+	opt, _ := m.selectedOption()
+	return opt.Value
+}
+
+// selectedOption is the option under the cursor; ok is false when there is
+// none.
+func (m model) selectedOption() (opt optionEntry, ok bool) {
 	if m.selected < 0 || m.selected >= len(m.options) {
-		return ""
+		return optionEntry{}, false
 	}
-	return m.options[m.selected].Value
+	return m.options[m.selected], true
 }
 
 func (m model) renderOptionsTable() string {
@@ -864,19 +2405,40 @@ func (m model) renderOptionsTable() string {
 		Background(lipgloss.Color("62")).
 		Foreground(lipgloss.Color("230")).
 		Bold(true)
+	if m.present {
+		selectedStyle = selectedStyle.
+			Background(lipgloss.Color("205")).
+			Foreground(lipgloss.Color("0")).
+			Underline(true)
+	}
 
 	normalStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("15"))
 
+	newStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("10"))
+
 	commentStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color(grayColor))
 
+	codeStyle := lipgloss.NewStyle().
+		Background(lipgloss.Color("236")).
+		Foreground(lipgloss.Color("252"))
+
+	var seen map[string]bool
+	if m.previousOptions != nil && !m.hideDiff {
+		seen = optionValueSet(m.previousOptions)
+	}
+
 	for i, opt := range m.options {
-		lines := m.optionLines(opt, i == m.selected)
+		isNew := seen != nil && !seen[opt.Value]
+		lines := m.optionLines(opt, i, i == m.selected, m.marked[i])
 		for _, ln := range lines.lines {
 			base := ln.prefix + ln.value
 			if ln.highlight {
 				base = selectedStyle.Render(base)
+			} else if isNew {
+				base = newStyle.Render(base)
 			} else {
 				base = normalStyle.Render(base)
 			}
@@ -886,13 +2448,69 @@ func (m model) renderOptionsTable() string {
 				continue
 			}
 
-			rows = append(rows, base+commentStyle.Render(ln.comment))
+			if m.noColor {
+				rows = append(rows, base+commentStyle.Render(ln.comment))
+				continue
+			}
+			rows = append(rows, base+renderInlineCode(ln.comment, commentStyle, codeStyle))
 		}
 	}
 
 	return strings.Join(rows, "\n")
 }
 
+// renderInlineCode renders `code` spans in s with code, dropping the
+// backticks, and the rest with text. Spans are matched per rendered line, so
+// one split by wrapping keeps its backticks, as does any unpaired backtick.
+func renderInlineCode(s string, text, code lipgloss.Style) string {
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(s, '`')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(s[open+1:], '`')
+		if end < 0 {
+			break
+		}
+		end += open + 1
+		if end == open+1 {
+			// "``" has nothing to style.
+			b.WriteString(text.Render(s[:end+1]))
+			s = s[end+1:]
+			continue
+		}
+		if open > 0 {
+			b.WriteString(text.Render(s[:open]))
+		}
+		b.WriteString(code.Render(s[open+1 : end]))
+		s = s[end+1:]
+	}
+	if s != "" {
+		b.WriteString(text.Render(s))
+	}
+	return b.String()
+}
+
+func optionValueSet(opts []optionEntry) map[string]bool {
+	set := make(map[string]bool, len(opts))
+	for _, opt := range opts {
+		set[opt.Value] = true
+	}
+	return set
+}
+
+func countNewOptions(previous, current []optionEntry) int {
+	seen := optionValueSet(previous)
+	fresh := 0
+	for _, opt := range current {
+		if !seen[opt.Value] {
+			fresh++
+		}
+	}
+	return fresh
+}
+
 func (m model) renderPromptHistory() string {
 	promptStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("12")).
@@ -993,7 +2611,7 @@ func (m model) buildHeader() (string, headerMeta) {
 	var leftSide strings.Builder
 	cursor := 0
 
-	logo := logoStyle.Render("✨ ")
+	logo := logoStyle.Render(icons.logo + " ")
 	leftSide.WriteString(logo)
 	cursor += lipgloss.Width(logo)
 
@@ -1027,13 +2645,15 @@ func (m model) buildHeader() (string, headerMeta) {
 		})
 	}
 
-	space := separatorStyle.Render(" ")
-	leftSide.WriteString(space)
-	cursor += lipgloss.Width(space)
+	if !m.present {
+		space := separatorStyle.Render(" ")
+		leftSide.WriteString(space)
+		cursor += lipgloss.Width(space)
 
-	ctrlHint := keyStyle.Render("ctrl+n/p")
-	leftSide.WriteString(ctrlHint)
-	cursor += lipgloss.Width(ctrlHint)
+		ctrlHint := keyStyle.Render("ctrl+n/p")
+		leftSide.WriteString(ctrlHint)
+		cursor += lipgloss.Width(ctrlHint)
+	}
 
 	leftWidth := lipgloss.Width(leftSide.String())
 
@@ -1049,7 +2669,11 @@ func (m model) buildHeader() (string, headerMeta) {
 	}
 
 	toggleText := toggleStyle.Render("yolo: " + yoloState)
-	rightSide := keyStyle.Render("ctrl+y") + descStyle.Render(" ") + toggleText
+	yoloKey := keyStyle.Render("ctrl+y") + descStyle.Render(" ")
+	if m.present {
+		yoloKey = ""
+	}
+	rightSide := yoloKey + toggleText
 	rightWidth := lipgloss.Width(rightSide)
 
 	spacing := ""
@@ -1063,7 +2687,7 @@ func (m model) buildHeader() (string, headerMeta) {
 
 	meta.yoloRegion = clickRegion{
 		kind:   "yolo",
-		startX: lipgloss.Width(leftSide.String()) + lipgloss.Width(spacing) + lipgloss.Width(keyStyle.Render("ctrl+y")+descStyle.Render(" ")),
+		startX: lipgloss.Width(leftSide.String()) + lipgloss.Width(spacing) + lipgloss.Width(yoloKey),
 		endX:   lipgloss.Width(header),
 		y:      0,
 	}
@@ -1082,7 +2706,7 @@ func (m model) View() string {
 		loadingStyle := lipgloss.NewStyle().
 			Foreground(lipgloss.Color("205")).
 			Bold(true)
-		return loadingStyle.Render("⏳ Loading...")
+		return loadingStyle.Render(icons.loading + " Loading...")
 	}
 
 	var b strings.Builder
@@ -1091,7 +2715,9 @@ func (m model) View() string {
 	b.WriteString(header)
 	b.WriteString("\n")
 
-	if m.running {
+	if m.showHelp {
+		b.WriteString(m.renderHelpOverlay())
+	} else if m.running {
 		// Show spinner animation
 		spinnerFrames := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
 		spinner := spinnerFrames[m.spinnerFrame%len(spinnerFrames)]
@@ -1099,7 +2725,15 @@ func (m model) View() string {
 		spinnerStyle := lipgloss.NewStyle().
 			Foreground(lipgloss.Color("10")).
 			Bold(true)
-		b.WriteString(spinnerStyle.Render(fmt.Sprintf("%s Running %s...", spinner, m.currentCLI().name)))
+		running := m.currentCLI().name
+		if m.fanOut != nil {
+			running = fmt.Sprintf("%d CLIs (%d/%d answered)", len(m.fanOut.clis), m.fanOut.answered(), len(m.fanOut.clis))
+		}
+		b.WriteString(spinnerStyle.Render(fmt.Sprintf("%s Running %s...", spinner, running)))
+		if !m.present {
+			hintStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(grayColor))
+			b.WriteString("  " + hintStyle.Render(m.runningHint()))
+		}
 		b.WriteString("\n")
 		if ph := strings.TrimSuffix(m.renderPromptHistory(), "\n"); ph != "" {
 			b.WriteString(ph)
@@ -1109,17 +2743,25 @@ func (m model) View() string {
 			b.WriteString(m.renderOptionsTable())
 			b.WriteString("\n")
 		}
+	} else if m.mode == modePickCLI {
+		b.WriteString(m.renderCLIPicker())
+	} else if m.mode == modeNameFavorite {
+		b.WriteString(m.renderNameFavorite())
+	} else if m.mode == modePickFavorite {
+		b.WriteString(m.renderFavorites())
 	} else if m.mode == modeViewing || m.mode == modeRefine {
 		if ph := strings.TrimSuffix(m.renderPromptHistory(), "\n"); ph != "" {
 			b.WriteString(ph)
 			b.WriteString("\n")
 		}
 
-		if m.lastError != nil {
+		if m.pendingRun != nil {
+			b.WriteString(m.renderConfirmPanel())
+		} else if m.lastError != nil {
 			errorStyle := lipgloss.NewStyle().
 				Foreground(lipgloss.Color("9")).
 				Bold(true)
-			b.WriteString(errorStyle.Render(fmt.Sprintf("❌ Error: %v", m.lastError)))
+			b.WriteString(errorStyle.Render(fmt.Sprintf("%s Error: %v", icons.fail, m.lastError)))
 			b.WriteString("\n")
 			if m.rawOutput != "" {
 				rawStyle := lipgloss.NewStyle().
@@ -1131,7 +2773,7 @@ func (m model) View() string {
 			errorStyle := lipgloss.NewStyle().
 				Foreground(lipgloss.Color("9")).
 				Bold(true)
-			b.WriteString(errorStyle.Render(fmt.Sprintf("❌ Parse error: %v", m.lastParseError)))
+			b.WriteString(errorStyle.Render(fmt.Sprintf("%s Parse error: %v", icons.fail, m.lastParseError)))
 			b.WriteString("\n")
 			if m.rawOutput != "" {
 				rawStyle := lipgloss.NewStyle().
@@ -1139,15 +2781,33 @@ func (m model) View() string {
 				b.WriteString(rawStyle.Render(m.rawOutput))
 				b.WriteString("\n")
 			}
+		} else if m.explanation != "" {
+			titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("14")).Bold(true)
+			b.WriteString(titleStyle.Render("Explanation: " + cleanText(m.selectedValue())))
+			b.WriteString("\n")
+			b.WriteString(m.renderRawOutput())
+		} else if m.raw && m.rawOutput != "" {
+			b.WriteString(m.renderRawOutput())
+		} else if m.clarifying != "" {
+			questionStyle := lipgloss.NewStyle().
+				Foreground(lipgloss.Color("14")).
+				Bold(true)
+			b.WriteString(questionStyle.Render(fmt.Sprintf("%s %s asks: %s", icons.hint, m.lastRun.cli, m.clarifying)))
+			b.WriteString("\n")
 		} else if len(m.options) == 0 {
 			warnStyle := lipgloss.NewStyle().
 				Foreground(lipgloss.Color("11")).
 				Bold(true)
-			b.WriteString(warnStyle.Render("⚠ No options returned"))
+			b.WriteString(warnStyle.Render(icons.warn + " No options returned"))
 			b.WriteString("\n")
 		} else {
-			b.WriteString(m.renderOptionsTable())
+			if m.splitActive() {
+				b.WriteString(m.renderSplitView())
+			} else {
+				b.WriteString(m.renderOptionsTable())
+			}
 			b.WriteString("\n")
+			b.WriteString(m.renderReasoningPanel())
 			// Add horizontal divider before status line
 			dividerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(grayColor))
 			dividerWidth := m.width - 10
@@ -1158,6 +2818,11 @@ func (m model) View() string {
 			b.WriteString("\n")
 		}
 
+		if footer := m.renderRunFooter(); footer != "" {
+			b.WriteString(footer)
+			b.WriteString("\n")
+		}
+
 		if strings.TrimSpace(m.execOutput) != "" {
 			outputLabel := lipgloss.NewStyle().Foreground(lipgloss.Color("14")).Bold(true)
 			outputText := lipgloss.NewStyle().Foreground(lipgloss.Color(grayColor))
@@ -1167,14 +2832,31 @@ func (m model) View() string {
 			b.WriteString("\n")
 		}
 
+		if m.showArgv && len(m.lastArgv) > 0 {
+			debugLabel := lipgloss.NewStyle().Foreground(lipgloss.Color("14")).Bold(true)
+			debugText := lipgloss.NewStyle().Foreground(lipgloss.Color(grayColor))
+			b.WriteString(debugLabel.Render("Last command:"))
+			b.WriteString("\n")
+			b.WriteString(debugText.Render(formatCommandLine(m.lastArgv)))
+			b.WriteString("\n")
+		}
+
 		if m.mode == modeRefine {
 			b.WriteString(m.renderInputArea())
 		}
 	} else {
 		b.WriteString(m.renderInputArea())
+		hintStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(grayColor)).Italic(true)
+		if m.raw {
+			b.WriteString(hintStyle.Render("   raw mode: the reply is shown as-is (ctrl+g to switch)"))
+			b.WriteString("\n")
+		} else if m.showInputHint && !m.present {
+			b.WriteString(hintStyle.Render("   responses will be parsed into selectable options"))
+			b.WriteString("\n")
+		}
 	}
 
-	if m.status != "" {
+	if m.status != "" && !(m.present && isHelpStatus(m.status)) {
 		// Style keyboard shortcuts differently from descriptions
 		keyStyle := lipgloss.NewStyle().
 			Foreground(lipgloss.Color("205")).
@@ -1184,63 +2866,11 @@ func (m model) View() string {
 		descStyle := lipgloss.NewStyle().
 			Foreground(lipgloss.Color(grayColor))
 
-		b.WriteString(descStyle.Render("💡 "))
-
-		// Build styled help text based on current status
-		if m.status == helpInput {
-			b.WriteString(keyStyle.Render("enter"))
-			b.WriteString(descStyle.Render(": send "))
-			b.WriteString(sepStyle.Render("• "))
-			b.WriteString(keyStyle.Render("ctrl+r"))
-			b.WriteString(descStyle.Render(": send & run "))
-			b.WriteString(sepStyle.Render("• "))
-			b.WriteString(keyStyle.Render("ctrl+y"))
-			b.WriteString(descStyle.Render(": toggle yolo "))
-			b.WriteString(sepStyle.Render("• "))
-			b.WriteString(keyStyle.Render("alt+enter"))
-			b.WriteString(descStyle.Render("/"))
-			b.WriteString(keyStyle.Render("ctrl+j"))
-			b.WriteString(descStyle.Render(": newline "))
-			b.WriteString(sepStyle.Render("• "))
-			b.WriteString(keyStyle.Render("esc"))
-			b.WriteString(descStyle.Render(": exit"))
-		} else if m.status == helpViewing {
-			b.WriteString(keyStyle.Render("enter"))
-			b.WriteString(descStyle.Render(": copy & exit "))
-			b.WriteString(sepStyle.Render("• "))
-			b.WriteString(keyStyle.Render("ctrl+r"))
-			b.WriteString(descStyle.Render(": run & exit "))
-			b.WriteString(sepStyle.Render("• "))
-			b.WriteString(keyStyle.Render("a"))
-			b.WriteString(descStyle.Render(": refine "))
-			b.WriteString(sepStyle.Render("• "))
-			b.WriteString(keyStyle.Render("n"))
-			b.WriteString(descStyle.Render(": new prompt "))
-			b.WriteString(sepStyle.Render("• "))
-			b.WriteString(keyStyle.Render("ctrl+y"))
-			b.WriteString(descStyle.Render(": toggle yolo "))
-			b.WriteString(sepStyle.Render("• "))
-			b.WriteString(keyStyle.Render("esc"))
-			b.WriteString(descStyle.Render("/"))
-			b.WriteString(keyStyle.Render("q"))
-			b.WriteString(descStyle.Render(": quit"))
-		} else if m.status == helpRefine {
-			b.WriteString(keyStyle.Render("enter"))
-			b.WriteString(descStyle.Render(": refine "))
-			b.WriteString(sepStyle.Render("• "))
-			b.WriteString(keyStyle.Render("ctrl+r"))
-			b.WriteString(descStyle.Render(": refine & run "))
-			b.WriteString(sepStyle.Render("• "))
-			b.WriteString(keyStyle.Render("ctrl+y"))
-			b.WriteString(descStyle.Render(": toggle yolo "))
-			b.WriteString(sepStyle.Render("• "))
-			b.WriteString(keyStyle.Render("alt+enter"))
-			b.WriteString(descStyle.Render("/"))
-			b.WriteString(keyStyle.Render("ctrl+j"))
-			b.WriteString(descStyle.Render(": newline "))
-			b.WriteString(sepStyle.Render("• "))
-			b.WriteString(keyStyle.Render("esc"))
-			b.WriteString(descStyle.Render(": exit"))
+		b.WriteString(descStyle.Render(icons.hint + " "))
+
+		// Style the keys in the default help statuses
+		if m.status == helpInput || m.status == helpViewing || m.status == helpRefine {
+			b.WriteString(renderHelpLine(m.status, keyStyle, descStyle, sepStyle))
 		} else {
 			// For other status messages, just render as-is
 			b.WriteString(descStyle.Render(m.status))
@@ -1250,26 +2880,213 @@ func (m model) View() string {
 	return b.String()
 }
 
-func execWithFeedback(value string, exitAfterExec bool, stayOpenExec bool) tea.Cmd {
+// renderHelpLine styles a "key: action • key: action" help status with the
+// keys highlighted. A part without a colon, such as "? for help", has its
+// first word as the key.
+func renderHelpLine(help string, keyStyle, descStyle, sepStyle lipgloss.Style) string {
+	var b strings.Builder
+	for i, part := range strings.Split(help, " • ") {
+		if i > 0 {
+			b.WriteString(sepStyle.Render(" • "))
+		}
+		key, desc, ok := strings.Cut(part, ": ")
+		if ok {
+			desc = ": " + desc
+		} else {
+			key, desc, _ = strings.Cut(part, " ")
+			desc = " " + desc
+		}
+		b.WriteString(keyStyle.Render(key))
+		b.WriteString(descStyle.Render(desc))
+	}
+	return b.String()
+}
+
+// pendingRun is a command about to run, held back with -confirm until the
+// user accepts it.
+type pendingRun struct {
+	value  string // the suggested value(s), chained, as recorded
+	script string // value with exec_template applied: what actually runs
+	label  string
+}
+
+// requestExec runs values, chained when there are several, or with -confirm
+// holds them in the confirm panel so the exact command, newlines included,
+// can be read first. Each value is checked against the exec_allow/exec_deny
+// policy before chaining; refused ones need a second ctrl+r.
+func (m model) requestExec(values []string, label string) (tea.Model, tea.Cmd) {
+	if m.style == styleAnswer {
+		m.status = fmt.Sprintf("%s answers aren't run (-style answer) • %s", icons.warn, helpViewing)
+		return m, nil
+	}
+	value, script := values[0], applyExecTemplate(m.execTemplate, values[0])
+	if len(values) > 1 {
+		wrapped := make([]string, len(values))
+		for i, v := range values {
+			wrapped[i] = applyExecTemplate(m.execTemplate, v)
+		}
+		value, script = chainCommands(values, m.continueOnError), chainCommands(wrapped, m.continueOnError)
+	}
+	if err := m.execPolicy.checkAll(values); err != nil && m.blockedRun != value {
+		m.blockedRun = value
+		m.status = fmt.Sprintf("%s blocked: %v • ctrl+r again to run anyway", icons.fail, err)
+		return m, nil
+	}
+	m.blockedRun = ""
+	run := pendingRun{value: value, script: script, label: label}
+	if !m.confirm {
+		return m.startExec(run)
+	}
+	m.pendingRun = &run
+	m.status = helpConfirm
+	return m, nil
+}
+
+func (m model) handleConfirmKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "y", "enter":
+		run := *m.pendingRun
+		m.pendingRun = nil
+		return m.startExec(run)
+	case "n", "esc", "q":
+		m.pendingRun = nil
+		m.status = "run cancelled • " + helpViewing
+	}
+	return m, nil
+}
+
+func (m model) renderConfirmPanel() string {
+	titleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true)
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("11")).
+		Padding(0, 1)
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(icons.warn + " Run this command?"))
+	b.WriteString("\n")
+	b.WriteString(boxStyle.Render(strings.TrimRight(m.pendingRun.script, "\n")))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// startExec runs run.script through the shell, or as a split argv in direct
+// exec mode. run.label replaces the default "running: <script>" status when
+// set.
+func (m model) startExec(run pendingRun) (tea.Model, tea.Cmd) {
+	value, label := run.script, run.label
+	var argv []string
+	if m.execMode == execModeDirect {
+		var err error
+		if argv, err = splitArgs(value); err != nil {
+			m.status = fmt.Sprintf("%s can't run directly: %v", icons.fail, err)
+			return m, nil
+		}
+	}
+	m.recordAction("ran", run.value)
+	// A daemon keeps its results open after a run, so the waiting -trigger
+	// client gets the value that ran now rather than an empty reply on esc.
+	m.replyTrigger(run.value)
+	ctx, cancel := context.WithCancel(context.Background())
+	m.execCancel = cancel
+	if label == "" {
+		label = fmt.Sprintf("running: %s", cleanText(value))
+	}
+	m.status = label
+	m.execOutput = ""
+	exitAfterExec := !m.stayOpenExec && !m.keepOpen
+	cleanup := func() {}
+	if argv == nil {
+		var err error
+		if argv, cleanup, err = scriptArgv(runtime.GOOS, value, m.scriptFileBytes); err != nil {
+			cancel()
+			m.execCancel = nil
+			m.status = fmt.Sprintf("%s %v", icons.fail, err)
+			return m, nil
+		}
+		if argv != nil {
+			value = scriptLabel(value)
+		}
+	}
+	return m, execWithFeedback(ctx, value, argv, cleanup, exitAfterExec, m.stayOpenExec)
+}
+
+// execWithFeedback runs value through the platform shell, or runs argv
+// without a shell when it is non-nil. cleanup runs once the command has
+// finished.
+func execWithFeedback(ctx context.Context, value string, argv []string, cleanup func(), exitAfterExec bool, stayOpenExec bool) tea.Cmd {
 	if stayOpenExec {
 		return func() tea.Msg {
-			cmd := exec.Command("sh", "-c", value)
-			out, err := cmd.CombinedOutput()
-			return execResultMsg{err: err, exit: false, output: string(out)}
+			defer cleanup()
+			cmd := shellCommand(ctx, value)
+			if argv != nil {
+				cmd = exec.CommandContext(ctx, argv[0], argv[1:]...)
+			}
+			out, err := combinedOutput(cmd)
+			interrupted := ctx.Err() != nil || isInterrupted(err)
+			return execResultMsg{err: err, exit: false, output: string(out), interrupted: interrupted}
 		}
 	}
 
-	// Wrap the command so the "running:" line prints on the normal screen (not the TUI alt screen).
-	cmd := exec.Command("sh", "-c", `printf "→ running: %s\n" "$1" >&2; exec sh -c "$1"`, "_", value)
+	cmd := passthroughCommand(ctx, runtime.GOOS, value, argv)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
 
+	// Bubble Tea ignores SIGINT while the terminal is released, so Ctrl+C only
+	// reaches the child; report that as an interruption instead of exiting.
 	return tea.ExecProcess(cmd, func(err error) tea.Msg {
-		return execResultMsg{err: err, exit: exitAfterExec}
+		cleanup()
+		interrupted := isInterrupted(err)
+		return execResultMsg{err: err, exit: exitAfterExec && !interrupted, interrupted: interrupted}
 	})
 }
 
+// chainCommands joins values into one script that runs them in order. Each
+// value is a brace group so multi-line values and directory changes carry
+// over; unless continueOnError is set, the first failure stops the chain.
+func chainCommands(values []string, continueOnError bool) string {
+	return chainCommandsFor(runtime.GOOS, values, continueOnError)
+}
+
+// pipeWithFeedback runs pipeCommand with value on stdin and reports its output
+// without leaving the TUI.
+func pipeWithFeedback(ctx context.Context, pipeCommand string, value string) tea.Cmd {
+	return func() tea.Msg {
+		cmd := shellCommand(ctx, pipeCommand)
+		cmd.Stdin = strings.NewReader(value)
+		out, err := combinedOutput(cmd)
+		interrupted := ctx.Err() != nil || isInterrupted(err)
+		return execResultMsg{err: err, output: string(out), interrupted: interrupted}
+	}
+}
+
+// formatCommandLine renders argv as a copy-pasteable shell command line.
+func formatCommandLine(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`!*?[](){}<>|&;#~") {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+func isInterrupted(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal() == syscall.SIGINT
+	}
+	// Shells that trap SIGINT exit with 128+2.
+	return exitErr.ExitCode() == 130
+}
+
 func logFatalSchema(err error) {
-	log.Fatalf("schema not found: %v", err)
+	fatalf("%s", schemaHelp(err))
 }
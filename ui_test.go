@@ -0,0 +1,1363 @@
+package instassist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func newTestModel(t *testing.T) model {
+	t.Helper()
+	fake := func(ctx context.Context, prompt string, yolo bool) *exec.Cmd {
+		return exec.CommandContext(ctx, "true")
+	}
+	clis := []cliOption{{name: "claude", runPrompt: fake}, {name: "codex", runPrompt: fake}}
+	m := newModelWithCLIs(clis, "claude", false, false, config{})
+	return update(t, m, tea.WindowSizeMsg{Width: 100, Height: 40})
+}
+
+func update(t *testing.T, m model, msg tea.Msg) model {
+	t.Helper()
+	next, _ := m.Update(msg)
+	nm, ok := next.(model)
+	if !ok {
+		t.Fatalf("Update returned %T, want model", next)
+	}
+	return nm
+}
+
+func submit(t *testing.T, m model, prompt string) (model, tea.Cmd) {
+	t.Helper()
+	m.input.SetValue(prompt)
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	return next.(model), cmd
+}
+
+const threeOptions = `{"options":[{"value":"b","description":"second","recommendation_order":2},{"value":"a","description":"first","recommendation_order":1},{"value":"c","description":"third","recommendation_order":3}]}`
+
+func TestModelWindowSizeMarksReady(t *testing.T) {
+	m := newTestModel(t)
+	if !m.ready || m.width != 100 || m.height != 40 {
+		t.Fatalf("unexpected size state: ready=%v width=%d height=%d", m.ready, m.width, m.height)
+	}
+	if m.mode != modeInput || m.status != helpInput {
+		t.Fatalf("expected input mode with help status, got mode=%v status=%q", m.mode, m.status)
+	}
+}
+
+func TestModelSubmitRunningThenViewing(t *testing.T) {
+	m := newTestModel(t)
+	m, cmd := submit(t, m, "list files")
+	if cmd == nil {
+		t.Fatal("expected a command to run the CLI")
+	}
+	if m.mode != modeRunning || !m.running || m.lastPrompt != "list files" {
+		t.Fatalf("expected running state, got mode=%v running=%v lastPrompt=%q", m.mode, m.running, m.lastPrompt)
+	}
+
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	if m.mode != modeViewing || m.running {
+		t.Fatalf("expected viewing mode, got mode=%v running=%v", m.mode, m.running)
+	}
+	if len(m.options) != 3 || m.options[0].Value != "a" {
+		t.Fatalf("expected sorted options, got %+v", m.options)
+	}
+	if m.selected != 0 || m.status != helpViewing {
+		t.Fatalf("unexpected selection/status: selected=%d status=%q", m.selected, m.status)
+	}
+}
+
+func TestModelEmptyPromptStaysInInput(t *testing.T) {
+	m := newTestModel(t)
+	m, cmd := submit(t, m, "   ")
+	if cmd != nil || m.mode != modeInput {
+		t.Fatalf("expected to stay in input mode, got mode=%v cmd=%v", m.mode, cmd != nil)
+	}
+	if !strings.HasPrefix(m.status, "prompt is empty") {
+		t.Fatalf("unexpected status %q", m.status)
+	}
+}
+
+func TestModelParseErrorPath(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte("sorry, I can't help with that"), cli: "claude"})
+	if m.mode != modeViewing {
+		t.Fatalf("expected viewing mode, got %v", m.mode)
+	}
+	if m.lastParseError == nil || len(m.options) != 0 {
+		t.Fatalf("expected parse error and no options, got err=%v options=%+v", m.lastParseError, m.options)
+	}
+	if !strings.HasPrefix(m.status, "parse error") {
+		t.Fatalf("unexpected status %q", m.status)
+	}
+	if m.rawOutput != "sorry, I can't help with that" {
+		t.Fatalf("expected raw output to be kept, got %q", m.rawOutput)
+	}
+}
+
+func TestModelCLIErrorPath(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{err: errors.New("exit status 1"), cli: "claude"})
+	if m.lastError == nil || m.rawOutput != "exit status 1" {
+		t.Fatalf("expected CLI error to be recorded, got err=%v raw=%q", m.lastError, m.rawOutput)
+	}
+	if !strings.HasPrefix(m.status, "error from claude") {
+		t.Fatalf("unexpected status %q", m.status)
+	}
+}
+
+func TestModelSelectionMovement(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+
+	steps := []struct {
+		key  tea.KeyMsg
+		want int
+	}{
+		{tea.KeyMsg{Type: tea.KeyDown}, 1},
+		{tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}}, 2},
+		{tea.KeyMsg{Type: tea.KeyDown}, 0},
+		{tea.KeyMsg{Type: tea.KeyUp}, 2},
+		{tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'k'}}, 1},
+	}
+	for i, step := range steps {
+		m = update(t, m, step.key)
+		if m.selected != step.want {
+			t.Fatalf("step %d: expected selection %d, got %d", i, step.want, m.selected)
+		}
+	}
+	if got := m.selectedValue(); got != "b" {
+		t.Fatalf("expected selected value %q, got %q", "b", got)
+	}
+}
+
+func TestModelPageNavigationClamps(t *testing.T) {
+	m := newTestModel(t)
+	m = update(t, m, tea.WindowSizeMsg{Width: 100, Height: 12})
+	m, _ = submit(t, m, "list files")
+	var sb strings.Builder
+	sb.WriteString(`{"options":[`)
+	for i := 0; i < 20; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(`{"value":"v","description":"d","recommendation_order":0}`)
+	}
+	sb.WriteString(`]}`)
+	m = update(t, m, responseMsg{output: []byte(sb.String()), cli: "claude"})
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlD})
+	if m.selected != 4 {
+		t.Fatalf("expected half-page jump to 4, got %d", m.selected)
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'G'}})
+	if m.selected != 19 {
+		t.Fatalf("expected G to jump to last option, got %d", m.selected)
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlD})
+	if m.selected != 19 {
+		t.Fatalf("expected ctrl+d to clamp at the end, got %d", m.selected)
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'g'}})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlU})
+	if m.selected != 0 {
+		t.Fatalf("expected ctrl+u to clamp at the top, got %d", m.selected)
+	}
+}
+
+func TestModelKeepOpenReturnsToViewing(t *testing.T) {
+	m := newTestModel(t)
+	m.keepOpen = true
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	m = next.(model)
+	if cmd == nil || m.execCancel == nil {
+		t.Fatal("expected exec command to start")
+	}
+	m.execCancel()
+
+	next, cmd = m.Update(execResultMsg{})
+	m = next.(model)
+	if cmd == nil {
+		t.Fatal("expected a window size query after the command returns")
+	}
+	if _, quit := cmd().(tea.QuitMsg); quit {
+		t.Fatal("expected no quit command with keep-open")
+	}
+	if m.mode != modeViewing || len(m.options) != 3 {
+		t.Fatalf("expected to stay on results, got mode=%v options=%d", m.mode, len(m.options))
+	}
+}
+
+func TestChainCommands(t *testing.T) {
+	values := []string{"cd /tmp", "ls\n"}
+	if got, want := chainCommands(values, false), "{\ncd /tmp\n} && {\nls\n}"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	if got, want := chainCommands(values, true), "{\ncd /tmp\n}\n{\nls\n}"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestModelMarkedValuesInDisplayOrder(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'G'}})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeySpace})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'g'}})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeySpace})
+
+	got := m.markedValues()
+	if len(got) != 2 || got[0] != "a" || got[1] != "c" {
+		t.Fatalf("expected [a c], got %v", got)
+	}
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeySpace})
+	if got := m.markedValues(); len(got) != 1 || got[0] != "c" {
+		t.Fatalf("expected unmark to leave [c], got %v", got)
+	}
+}
+
+func TestModelParsesPostprocessedOutput(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{
+		output:    []byte(`{"result":"wrapped"}`),
+		processed: []byte(threeOptions),
+		cli:       "claude",
+	})
+	if len(m.options) != 3 {
+		t.Fatalf("expected options from processed output, got %+v (err %v)", m.options, m.lastParseError)
+	}
+	if m.rawOutput != `{"result":"wrapped"}` {
+		t.Fatalf("expected raw output to stay unprocessed, got %q", m.rawOutput)
+	}
+}
+
+func TestModelPostprocessFailureKeepsRegenerateStatus(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}})
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude", postprocessErr: errors.New("exit status 1")})
+	if !strings.Contains(m.status, "postprocess failed") || !strings.Contains(m.status, "regenerated: 0 new, 3 repeated") {
+		t.Fatalf("status = %q, want the postprocess warning and the regenerate summary", m.status)
+	}
+}
+
+func TestPostprocessOutput(t *testing.T) {
+	out, err := postprocessOutput(context.Background(), "tr a-z A-Z", []byte("abc"))
+	if err != nil || string(out) != "ABC" {
+		t.Fatalf("expected ABC, got %q (err %v)", out, err)
+	}
+	if _, err := postprocessOutput(context.Background(), "echo boom >&2; exit 3", nil); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected error with stderr, got %v", err)
+	}
+}
+
+func TestModelSpinnerPausesWhileBlurred(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	if !m.ticking {
+		t.Fatal("expected spinner to start ticking on submit")
+	}
+
+	m = update(t, m, tea.BlurMsg{})
+	next, cmd := m.Update(tickMsg{})
+	m = next.(model)
+	if cmd != nil || m.ticking {
+		t.Fatal("expected tick loop to stop while blurred")
+	}
+
+	next, cmd = m.Update(tea.FocusMsg{})
+	m = next.(model)
+	if cmd == nil || !m.ticking {
+		t.Fatal("expected tick loop to resume on focus")
+	}
+	if _, cmd = m.Update(tea.FocusMsg{}); cmd != nil {
+		t.Fatal("expected no second tick loop on repeated focus")
+	}
+}
+
+func TestModelCachedResponseStatus(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude", cached: true})
+	if len(m.options) != 3 || !strings.HasPrefix(m.status, "cached response") {
+		t.Fatalf("expected cached options and status, got %d options, status %q", len(m.options), m.status)
+	}
+}
+
+func TestModelCLIPicker(t *testing.T) {
+	m := newTestModel(t)
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlO})
+	if m.mode != modePickCLI || m.pickerIndex != 0 {
+		t.Fatalf("expected picker on current CLI, got mode=%v index=%d", m.mode, m.pickerIndex)
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyDown})
+	if m.currentCLI().name != "claude" {
+		t.Fatal("expected CLI to change only on enter")
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyEnter})
+	if m.mode != modeInput || m.currentCLI().name != "codex" || m.status != helpInput {
+		t.Fatalf("expected input mode with codex, got mode=%v cli=%s status=%q", m.mode, m.currentCLI().name, m.status)
+	}
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlO})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyDown})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.mode != modeInput || m.currentCLI().name != "codex" {
+		t.Fatalf("expected esc to cancel, got mode=%v cli=%s", m.mode, m.currentCLI().name)
+	}
+}
+
+func TestModelPresentModeSpacesOptionsForClicks(t *testing.T) {
+	m := newTestModel(t)
+	m.present = true
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+
+	top := m.optionsTop()
+	if got := m.optionIndexAt(top + 2); got != 1 {
+		t.Fatalf("expected row %d to hit the second option, got %d", top+2, got)
+	}
+	if strings.Contains(m.View(), "enter: copy & exit") {
+		t.Fatal("expected key help to be hidden in presentation mode")
+	}
+}
+
+func TestModelSessionResumesForNewPrompts(t *testing.T) {
+	var resumed string
+	fake := func(ctx context.Context, prompt string, yolo bool) *exec.Cmd {
+		return exec.CommandContext(ctx, "true")
+	}
+	resume := func(ctx context.Context, prompt, sessionID string, yolo bool) *exec.Cmd {
+		resumed = sessionID
+		return exec.CommandContext(ctx, "true")
+	}
+	clis := []cliOption{{name: "claude", runPrompt: fake, resumePrompt: resume}}
+	m := newModelWithCLIs(clis, "claude", false, false, config{Session: true})
+	m = update(t, m, tea.WindowSizeMsg{Width: 100, Height: 40})
+	m.sessionIDs["claude"] = "sess-1"
+
+	m, cmd := submit(t, m, "next question")
+	for _, c := range cmd().(tea.BatchMsg) {
+		if msg, ok := c().(responseMsg); ok {
+			m = update(t, m, msg)
+		}
+	}
+	if resumed != "sess-1" {
+		t.Fatalf("expected the new prompt to resume sess-1, got %q", resumed)
+	}
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if len(m.sessionIDs) != 0 {
+		t.Fatalf("expected n to start a fresh session, got %v", m.sessionIDs)
+	}
+}
+
+func TestModelMaxValueWidthTruncatesDisplayOnly(t *testing.T) {
+	m := newTestModel(t)
+	m.maxValueWidth = 8
+	opt := optionEntry{Value: "find . -name '*.go' -print"}
+	lines := m.optionLines(opt, 0, true, false)
+	if got := lines.lines[0].value; got != "find . …" {
+		t.Fatalf("expected truncated display value, got %q", got)
+	}
+	if opt.Value != "find . -name '*.go' -print" {
+		t.Fatalf("expected the option value to be untouched, got %q", opt.Value)
+	}
+}
+
+func TestModelParseRetriesThenGivesUp(t *testing.T) {
+	m := newTestModel(t)
+	m.maxParseRetries = 2
+	m, _ = submit(t, m, "list files")
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		m = update(t, m, responseMsg{output: []byte("Sure! Here you go."), cli: "claude"})
+		if m.mode != modeRunning || m.parseRetries != attempt {
+			t.Fatalf("attempt %d: expected a retry, got mode=%v retries=%d", attempt, m.mode, m.parseRetries)
+		}
+		if want := fmt.Sprintf("retrying for valid JSON (%d/2)", attempt); m.status != want {
+			t.Fatalf("expected status %q, got %q", want, m.status)
+		}
+		if !strings.HasSuffix(m.lastDispatch, "list files") {
+			t.Fatalf("expected retries to keep the original prompt, got %q", m.lastDispatch)
+		}
+	}
+
+	m = update(t, m, responseMsg{output: []byte("still prose"), cli: "claude"})
+	if m.mode != modeViewing || m.lastParseError == nil {
+		t.Fatalf("expected to give up with a parse error, got mode=%v err=%v", m.mode, m.lastParseError)
+	}
+}
+
+func TestModelRunFooter(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude", elapsed: 2340 * time.Millisecond})
+	if got := m.renderRunFooter(); !strings.Contains(got, "claude • 2.3s • 3 options") {
+		t.Fatalf("unexpected footer %q", got)
+	}
+
+	m.resetForNewPrompt()
+	if got := m.renderRunFooter(); got != "" {
+		t.Fatalf("expected no footer after a reset, got %q", got)
+	}
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude", cached: true})
+	if got := m.renderRunFooter(); !strings.Contains(got, "claude • cached • 3 options") {
+		t.Fatalf("unexpected cached footer %q", got)
+	}
+}
+
+func TestModelAutoSubmitOnStart(t *testing.T) {
+	m := newTestModel(t)
+	m.input.SetValue("list files")
+	m.autoSubmit = true
+	cmd := m.Init()
+	if cmd == nil {
+		t.Fatal("expected Init to schedule the auto-submit")
+	}
+	m = update(t, m, cmd())
+	if m.mode != modeRunning || m.lastPrompt != "list files" {
+		t.Fatalf("expected the pre-filled prompt to be sent, got mode=%v lastPrompt=%q", m.mode, m.lastPrompt)
+	}
+}
+
+func TestModelClarifyingQuestionFollowUp(t *testing.T) {
+	m := newTestModel(t)
+	m.maxParseRetries = 2
+	m, _ = submit(t, m, "find big files")
+	m = update(t, m, responseMsg{output: []byte("Which directory should I search?"), cli: "claude"})
+	if m.mode != modeRefine || m.clarifying != "Which directory should I search?" {
+		t.Fatalf("expected to ask for an answer, got mode=%v clarifying=%q", m.mode, m.clarifying)
+	}
+	if !strings.Contains(m.View(), "claude asks: Which directory should I search?") {
+		t.Fatal("expected the question to be shown")
+	}
+
+	m, _ = submit(t, m, "/var/log")
+	if m.mode != modeRunning || m.clarifying != "" {
+		t.Fatalf("expected the answer to be sent, got mode=%v", m.mode)
+	}
+	want := "find big files\nYou asked: Which directory should I search?\nMy answer: /var/log"
+	if m.lastDispatch != want {
+		t.Fatalf("expected follow-up %q, got %q", want, m.lastDispatch)
+	}
+}
+
+func TestModelHelpOverlay(t *testing.T) {
+	m := newTestModel(t)
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("?")})
+	if m.showHelp || m.input.Value() != "?" {
+		t.Fatalf("expected ? to be typed in input mode, got showHelp=%v input=%q", m.showHelp, m.input.Value())
+	}
+
+	m.input.SetValue("")
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("?")})
+	if !m.showHelp || !strings.Contains(m.View(), "CLI picker") {
+		t.Fatal("expected ? to open the help overlay in results")
+	}
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	if m.showHelp || m.selected != 0 {
+		t.Fatalf("expected the key to only close the overlay, got showHelp=%v selected=%d", m.showHelp, m.selected)
+	}
+}
+
+func TestModelDirectExecRefusesShellSyntax(t *testing.T) {
+	m := newTestModel(t)
+	m.execMode = execModeDirect
+	m, _ = submit(t, m, "count files")
+	m = update(t, m, responseMsg{output: []byte(`{"options":[{"value":"ls | wc -l","recommendation_order":1}]}`), cli: "claude"})
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	m = next.(model)
+	if cmd != nil || m.execCancel != nil {
+		t.Fatal("expected nothing to run")
+	}
+	if !strings.Contains(m.status, "can't run directly") {
+		t.Fatalf("unexpected status %q", m.status)
+	}
+}
+
+func TestModelInputHintHiddenAfterSuccess(t *testing.T) {
+	m := newTestModel(t)
+	const hint = "responses will be parsed into selectable options"
+	if !strings.Contains(m.View(), hint) {
+		t.Fatal("expected the input hint before the first run")
+	}
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if strings.Contains(m.View(), hint) {
+		t.Fatal("expected the input hint to be hidden after a successful run")
+	}
+
+	hidden := newModelWithCLIs(m.cliOptions, "claude", false, false, config{HideInputHint: true})
+	if strings.Contains(hidden.View(), hint) {
+		t.Fatal("expected hide_input_hint to hide the hint")
+	}
+}
+
+func TestModelRawModeShowsWholeReply(t *testing.T) {
+	m := newTestModel(t)
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlG})
+	if !m.raw {
+		t.Fatal("expected ctrl+g to enable raw mode")
+	}
+	m, _ = submit(t, m, "explain tar")
+	reply := strings.Repeat("line\n", 60) + `{"options":[{"value":"ignored"}]}`
+	m = update(t, m, responseMsg{output: []byte(reply), cli: "claude"})
+	if m.mode != modeViewing || m.options != nil || m.lastParseError != nil {
+		t.Fatalf("expected the raw reply without parsing, got mode=%v options=%v err=%v", m.mode, m.options, m.lastParseError)
+	}
+	if m.lastDispatch != "explain tar" || m.rawOutput != strings.TrimSpace(reply) {
+		t.Fatalf("expected the plain prompt and whole reply, got prompt=%q", m.lastDispatch)
+	}
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	if m.rawScroll != 1 || !strings.Contains(m.View(), "lines 2-") {
+		t.Fatalf("expected j to scroll the raw output, got scroll=%d", m.rawScroll)
+	}
+}
+
+func TestModelExplainOption(t *testing.T) {
+	var asked string
+	fake := func(ctx context.Context, prompt string, yolo bool) *exec.Cmd {
+		asked = prompt
+		return exec.CommandContext(ctx, "echo", "It lists files.")
+	}
+	m := newModelWithCLIs([]cliOption{{name: "claude", runPrompt: fake, runRaw: fake}}, "claude", false, false, config{})
+	m = update(t, m, tea.WindowSizeMsg{Width: 100, Height: 40})
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	m = next.(model)
+	if cmd == nil {
+		t.Fatal("expected an explain command")
+	}
+	m = update(t, m, cmd())
+	if !strings.Contains(asked, "Suggestion: a") || !strings.Contains(asked, "Original request: list files") {
+		t.Fatalf("unexpected explain prompt %q", asked)
+	}
+	if m.explanation != "It lists files." || !strings.Contains(m.View(), "Explanation: a") {
+		t.Fatalf("expected the explanation view, got %q", m.explanation)
+	}
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.explanation != "" || len(m.options) != 3 || m.status != helpViewing {
+		t.Fatalf("expected esc to return to the options, got explanation=%q", m.explanation)
+	}
+}
+
+func TestModelActedTracksExitStatus(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if _, ok := cmd().(tea.QuitMsg); !ok || next.(model).acted {
+		t.Fatal("expected esc to quit without marking an action")
+	}
+
+	next, _ = m.Update(execResultMsg{exit: true})
+	if !next.(model).acted {
+		t.Fatal("expected a finished command to count as an action")
+	}
+}
+
+func TestModelConfirmBeforeRun(t *testing.T) {
+	m := newModelWithCLIs(newTestModel(t).cliOptions, "claude", false, false, config{Confirm: true})
+	m = update(t, m, tea.WindowSizeMsg{Width: 100, Height: 40})
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(`{"options":[{"value":"cd /tmp\nls -la","description":"two lines","recommendation_order":1}]}`), cli: "claude"})
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	m = next.(model)
+	if cmd != nil || m.pendingRun == nil || m.status != helpConfirm {
+		t.Fatal("expected ctrl+r to wait for confirmation")
+	}
+	if view := m.View(); !strings.Contains(view, "cd /tmp") || !strings.Contains(view, "ls -la") || strings.Contains(view, "cd /tmp ls -la") {
+		t.Fatalf("expected the command with its newline intact, got %q", view)
+	}
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.pendingRun != nil || m.execCancel != nil {
+		t.Fatal("expected esc to cancel without running")
+	}
+
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	next, cmd = next.(model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	if cmd == nil || next.(model).pendingRun != nil || next.(model).execCancel == nil {
+		t.Fatal("expected y to start the command")
+	}
+}
+
+func TestModelViewDetailCycle(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	line := func() string { return m.optionLines(m.options[0], 0, false, false).lines[0].comment }
+
+	if !strings.Contains(line(), "# ") || strings.Contains(line(), "order") {
+		t.Fatalf("expected the description by default, got %q", line())
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("v")})
+	if !strings.Contains(line(), "(order 1)") {
+		t.Fatalf("expected the order after one v, got %q", line())
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("v")})
+	if line() != "" {
+		t.Fatalf("expected values only after two v presses, got %q", line())
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("v")})
+	if m.viewDetail != detailDescriptions {
+		t.Fatalf("expected v to wrap around, got %v", m.viewDetail)
+	}
+}
+
+func TestModelShowOrderFalseSkipsOrderView(t *testing.T) {
+	hide := false
+	m := newModelWithCLIs(newTestModel(t).cliOptions, "claude", false, false, config{ShowOrder: &hide})
+	m = update(t, m, tea.WindowSizeMsg{Width: 100, Height: 40})
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	if m.options[0].Value != "a" {
+		t.Fatalf("expected sorting by order to be unchanged, got %q first", m.options[0].Value)
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("v")})
+	if m.viewDetail != detailValues {
+		t.Fatalf("expected v to skip the order view, got %v", m.viewDetail)
+	}
+	m.viewDetail = detailOrder
+	if comment := m.optionLines(m.options[0], 0, false, false).lines[0].comment; strings.Contains(comment, "order") {
+		t.Fatalf("expected no order shown, got %q", comment)
+	}
+}
+
+func TestModelExecPolicyNeedsOverride(t *testing.T) {
+	m := newTestModel(t)
+	policy, err := newExecPolicy(nil, []string{`^b$`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.execPolicy = policy
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	m.selected = 1 // "b"
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	m = next.(model)
+	if cmd != nil || m.execCancel != nil || !strings.Contains(m.status, "blocked") {
+		t.Fatalf("expected the denied value to be blocked, status=%q", m.status)
+	}
+	next, cmd = m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if cmd == nil || next.(model).execCancel == nil {
+		t.Fatal("expected a second ctrl+r to run it anyway")
+	}
+}
+
+func TestModelExecPolicyChecksEachMarkedValue(t *testing.T) {
+	m := newTestModel(t)
+	policy, err := newExecPolicy([]string{`^(a|b)\b`}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.execPolicy = policy
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeySpace}) // a
+	m.selected = 2
+	m = update(t, m, tea.KeyMsg{Type: tea.KeySpace}) // c
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if cmd != nil || next.(model).execCancel != nil || !strings.Contains(next.(model).status, "blocked") {
+		t.Fatalf("expected the chain to be blocked by its second value, status=%q", next.(model).status)
+	}
+}
+
+func TestRenderInlineCode(t *testing.T) {
+	text := lipgloss.NewStyle()
+	code := lipgloss.NewStyle().Transform(func(s string) string { return "[" + s + "]" })
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "  # use `ls -la` here", want: "  # use [ls -la] here"},
+		{in: "  # split `across", want: "  # split `across"},
+		{in: "empty `` ticks", want: "empty `` ticks"},
+		{in: "`a` and `b`", want: "[a] and [b]"},
+	}
+	for _, tt := range tests {
+		if got := renderInlineCode(tt.in, text, code); got != tt.want {
+			t.Errorf("renderInlineCode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestModelNumberedOptions(t *testing.T) {
+	m := newModelWithCLIs(newTestModel(t).cliOptions, "claude", false, false, config{Numbered: true})
+	m = update(t, m, tea.WindowSizeMsg{Width: 100, Height: 40})
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+
+	if got := m.optionLines(m.options[0], 0, true, false).lines[0].prefix; got != "▶ 1. " {
+		t.Fatalf("expected the arrow and number, got %q", got)
+	}
+	if got := m.optionLines(m.options[2], 2, false, false).lines[0].prefix; got != "  3. " {
+		t.Fatalf("expected the third row numbered, got %q", got)
+	}
+}
+
+func TestModelAskForMore(t *testing.T) {
+	var asked string
+	fake := func(ctx context.Context, prompt string, yolo bool) *exec.Cmd {
+		asked = prompt
+		return exec.CommandContext(ctx, "true")
+	}
+	m := newModelWithCLIs([]cliOption{{name: "claude", runPrompt: fake}}, "claude", false, false, config{NoCache: true})
+	m = update(t, m, tea.WindowSizeMsg{Width: 100, Height: 40})
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(`{"options":[{"value":"ls","description":"list","recommendation_order":1}]}`), cli: "claude"})
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("+")})
+	m = next.(model)
+	if cmd == nil || !m.running {
+		t.Fatal("expected + to re-ask the CLI")
+	}
+	cmd()
+	if !strings.Contains(asked, "list files\nGive at least 5 distinct alternatives") || !strings.Contains(asked, "earlier suggestions: ls") {
+		t.Fatalf("unexpected prompt %q", asked)
+	}
+	if len(m.previousOptions) != 1 {
+		t.Fatal("expected the shown options to be kept for highlighting")
+	}
+}
+
+func TestModelMouseWheelAndDoubleClick(t *testing.T) {
+	var copied string
+	defer func(r func() (string, error), w func(string) error) {
+		readClipboard, writeClipboard = r, w
+	}(readClipboard, writeClipboard)
+	readClipboard = func() (string, error) { return "", errors.New("unavailable") }
+	writeClipboard = func(s string) error {
+		copied = s
+		return nil
+	}
+
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+
+	m = update(t, m, tea.MouseMsg{Button: tea.MouseButtonWheelDown, Action: tea.MouseActionPress})
+	if m.selected != 1 {
+		t.Fatalf("expected the wheel to move the selection, got %d", m.selected)
+	}
+
+	row := m.optionsTop() + 2 // third option, one line each
+	click := tea.MouseMsg{X: 5, Y: row, Button: tea.MouseButtonLeft, Action: tea.MouseActionPress}
+	next, cmd := m.Update(click)
+	m = next.(model)
+	if m.selected != 2 || cmd != nil {
+		t.Fatalf("expected a single click to select only, got selected=%d", m.selected)
+	}
+	next, cmd = m.Update(click)
+	if cmd == nil || copied != "c" || !next.(model).acted {
+		t.Fatalf("expected a double-click to copy, copied=%q", copied)
+	}
+}
+
+func TestModelAnswerStyle(t *testing.T) {
+	m := newModelWithCLIs(newTestModel(t).cliOptions, "claude", false, false, config{Style: styleAnswer})
+	m = update(t, m, tea.WindowSizeMsg{Width: 100, Height: 40})
+	m, _ = submit(t, m, "capital of France")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	if len(m.options) != 1 || m.options[0].Value != "a" {
+		t.Fatalf("expected only the best answer, got %+v", m.options)
+	}
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if cmd != nil || next.(model).execCancel != nil {
+		t.Fatal("expected answers not to run")
+	}
+}
+
+func TestModelRecoversPartialOutput(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "back up src")
+	partial := `{"options":[{"value":"tar -czf b.tgz src","description":"compress","recommendation_order":1},{"value":"rsy`
+	m = update(t, m, responseMsg{output: []byte(partial), cli: "claude", err: context.DeadlineExceeded})
+	if m.lastError != nil || len(m.options) != 1 || !strings.Contains(m.status, "recovered 1 option from partial output") {
+		t.Fatalf("expected the complete option to be recovered, status=%q", m.status)
+	}
+}
+
+func TestModelYankAppends(t *testing.T) {
+	board := "first"
+	defer func(r func() (string, error), w func(string) error) {
+		readClipboard, writeClipboard = r, w
+	}(readClipboard, writeClipboard)
+	readClipboard = func() (string, error) { return board, nil }
+	writeClipboard = func(s string) error {
+		board = s
+		return nil
+	}
+
+	m := newModelWithCLIs(newTestModel(t).cliOptions, "claude", false, false, config{Append: true, AppendSeparator: " | "})
+	m = update(t, m, tea.WindowSizeMsg{Width: 100, Height: 40})
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	if cmd != nil || next.(model).mode != modeViewing {
+		t.Fatal("expected y to keep the results open")
+	}
+	if board != "first | a" || !strings.Contains(next.(model).status, "Appended") {
+		t.Fatalf("expected the value appended, got %q", board)
+	}
+}
+
+func TestModelSaveAndRecallFavorite(t *testing.T) {
+	m := newTestModel(t)
+	m.favoritesPath = filepath.Join(t.TempDir(), "favorites.json")
+
+	m.input.SetValue("list open ports")
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlS})
+	if m.mode != modeNameFavorite {
+		t.Fatalf("mode = %v, want modeNameFavorite", m.mode)
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("ports")})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyEnter})
+	if m.mode != modeInput {
+		t.Fatalf("mode after save = %v, want modeInput", m.mode)
+	}
+
+	m.input.SetValue("")
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlF})
+	if m.mode != modePickFavorite {
+		t.Fatalf("mode = %v, want modePickFavorite (status %q)", m.mode, m.status)
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyEnter})
+	if got := m.input.Value(); got != "list open ports" {
+		t.Errorf("recalled prompt = %q, want %q", got, "list open ports")
+	}
+	if m.mode != modeInput {
+		t.Errorf("mode after recall = %v, want modeInput", m.mode)
+	}
+}
+
+func TestModelTimingsCountResponses(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude", elapsed: 2 * time.Second})
+	m.resetForNewPrompt()
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude", cached: true})
+
+	if m.timings.subprocess != 2*time.Second || m.timings.calls != 1 || m.timings.cached != 1 {
+		t.Fatalf("timings = %+v, want 2s over 1 call and 1 cached", m.timings)
+	}
+	summary := m.timings.summary(5 * time.Second)
+	if !strings.Contains(summary, "subprocess") || !strings.Contains(summary, "idle") {
+		t.Errorf("summary missing a line:\n%s", summary)
+	}
+}
+
+func TestDispatchRecoversFromPanickingCLI(t *testing.T) {
+	m := newTestModel(t)
+	m.cliOptions[0].runPrompt = func(ctx context.Context, prompt string, yolo bool) *exec.Cmd {
+		panic("boom")
+	}
+	next, cmd := m.dispatchPrompt("list files", "", false)
+	m = next.(model)
+
+	batch, ok := cmd().(tea.BatchMsg)
+	if !ok || len(batch) == 0 {
+		t.Fatalf("dispatch cmd returned %T, want a batch", batch)
+	}
+	resp, ok := batch[0]().(responseMsg)
+	if !ok || resp.err == nil || !strings.Contains(resp.err.Error(), "internal error: boom") {
+		t.Fatalf("response = %#v, want an internal error", resp)
+	}
+	m = update(t, m, resp)
+	if !strings.Contains(m.status, "internal error") {
+		t.Errorf("status = %q, want the internal error shown", m.status)
+	}
+}
+
+func TestModelEmptyOptionsRetriesThenGivesUp(t *testing.T) {
+	m := newTestModel(t)
+	m.maxEmptyRetries = 1
+	m, _ = submit(t, m, "list files")
+
+	m = update(t, m, responseMsg{output: []byte(`{"options":[]}`), cli: "claude"})
+	if m.mode != modeRunning || m.emptyRetries != 1 || m.parseRetries != 0 {
+		t.Fatalf("expected an empty-options retry, got mode=%v empty=%d parse=%d", m.mode, m.emptyRetries, m.parseRetries)
+	}
+	if !strings.HasSuffix(m.lastDispatch, "list files") {
+		t.Fatalf("expected the retry to keep the original prompt, got %q", m.lastDispatch)
+	}
+
+	m = update(t, m, responseMsg{output: []byte(`{"options":[]}`), cli: "claude"})
+	if m.mode != modeViewing || m.lastParseError != nil {
+		t.Fatalf("expected to give up without a parse error, got mode=%v err=%v", m.mode, m.lastParseError)
+	}
+	if !strings.Contains(m.status, "claude returned no options") {
+		t.Fatalf("unexpected status %q", m.status)
+	}
+}
+
+func TestModelSplitView(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	m = update(t, m, tea.WindowSizeMsg{Width: 80, Height: 40})
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	if m.splitActive() || !strings.Contains(m.status, "needs 100 columns") {
+		t.Fatalf("expected a narrow window to refuse the split, got active=%v status=%q", m.splitActive(), m.status)
+	}
+
+	m = update(t, m, tea.WindowSizeMsg{Width: 140, Height: 40})
+	if !m.splitActive() {
+		t.Fatal("expected the split view once the window is wide enough")
+	}
+	view := m.View()
+	if !strings.Contains(view, `"recommendation_order"`) {
+		t.Fatalf("expected the raw JSON pane in the view:\n%s", view)
+	}
+	if idx := m.optionIndexAt(m.optionsTop()); idx != 0 {
+		t.Errorf("clicking the first row selects %d, want 0", idx)
+	}
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	if m.splitActive() {
+		t.Fatal("expected s to close the split view")
+	}
+}
+
+func TestModelRestoreResultsAfterNewPrompt(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyDown})
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if m.mode != modeInput || len(m.options) != 0 {
+		t.Fatalf("expected a fresh prompt, got mode=%v options=%d", m.mode, len(m.options))
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlZ})
+	if m.mode != modeViewing || len(m.options) != 3 || m.selected != 1 {
+		t.Fatalf("expected the results back with the selection, got mode=%v options=%d selected=%d", m.mode, len(m.options), m.selected)
+	}
+
+	m.resetForNewPrompt()
+	m, _ = submit(t, m, "disk usage")
+	if m.stash != nil {
+		t.Fatal("expected sending a new prompt to drop the stash")
+	}
+}
+
+func TestModelTranscriptRecordsExchanges(t *testing.T) {
+	defer func(r func() (string, error), w func(string) error) {
+		readClipboard, writeClipboard = r, w
+	}(readClipboard, writeClipboard)
+	readClipboard = func() (string, error) { return "", nil }
+	writeClipboard = func(string) error { return nil }
+
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+
+	if len(m.transcript) != 1 {
+		t.Fatalf("expected 1 exchange, got %d", len(m.transcript))
+	}
+	text := formatTranscript(time.Now(), m.transcript)
+	for _, want := range []string{"## 1. claude", "  list files", "1. a — ", "copied: a", "Raw output:"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("transcript missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestModelSanitizesInvalidUTF8(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	reply := []byte("{\"options\":[{\"value\":\"ls \xff\xfe\",\"description\":\"bad \xc3(bytes\"}]}")
+	m = update(t, m, responseMsg{output: reply, cli: "claude"})
+	if !utf8.ValidString(m.rawOutput) {
+		t.Fatalf("raw output is not valid UTF-8: %q", m.rawOutput)
+	}
+	if len(m.options) != 1 || m.options[0].Value != "ls \uFFFD" || !utf8.ValidString(m.options[0].Description) {
+		t.Fatalf("unexpected options: %+v", m.options)
+	}
+	if view := m.View(); !utf8.ValidString(view) {
+		t.Fatal("view is not valid UTF-8")
+	}
+}
+
+func TestModelTranscriptStoresFullPrompt(t *testing.T) {
+	m := newTestModel(t)
+	m.storeFullPrompt = true
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	if len(m.transcript) != 1 {
+		t.Fatalf("expected 1 exchange, got %d", len(m.transcript))
+	}
+	got := m.transcript[0].prompt
+	if got == "list files" || !strings.Contains(got, "list files") || got != m.lastFullPrompt {
+		t.Fatalf("expected the assembled prompt, got %q", got)
+	}
+}
+
+func TestModelEscCancelsRun(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	stale := responseMsg{output: []byte(threeOptions), cli: "claude", gen: m.runGen}
+	if view := m.View(); !strings.Contains(view, "esc: cancel • tab: switch to codex") {
+		t.Fatalf("expected the running view to list the keys:\n%s", view)
+	}
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = next.(model)
+	if cmd != nil || m.running || m.mode != modeInput || m.input.Value() != "list files" {
+		t.Fatalf("expected to be back at the prompt, got running=%v mode=%v input=%q", m.running, m.mode, m.input.Value())
+	}
+	m = update(t, m, stale)
+	if len(m.options) != 0 || m.mode != modeInput {
+		t.Fatal("expected the cancelled reply to be dropped")
+	}
+}
+
+func TestModelRerunOnNextCLIDropsAbandonedReply(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	stale := responseMsg{output: []byte(threeOptions), cli: "claude", gen: m.runGen}
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyTab})
+	if !m.running || m.currentCLI().name != "codex" {
+		t.Fatalf("expected a new run on codex, got running=%v cli=%s", m.running, m.currentCLI().name)
+	}
+	if !strings.HasSuffix(m.lastDispatch, "list files") {
+		t.Fatalf("expected the same prompt, got %q", m.lastDispatch)
+	}
+
+	m = update(t, m, stale)
+	if !m.running || len(m.options) != 0 {
+		t.Fatal("expected the abandoned claude reply to be ignored")
+	}
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "codex", gen: m.runGen})
+	if m.running || len(m.options) != 3 {
+		t.Fatalf("expected the codex reply to be shown, got running=%v options=%d", m.running, len(m.options))
+	}
+}
+
+func TestModelRefusesPromptOverLimit(t *testing.T) {
+	m := newTestModel(t)
+	m.promptLimit = promptLimit{Max: 50}
+	m, cmd := submit(t, m, strings.Repeat("word ", 40))
+	if cmd != nil || m.running {
+		t.Fatalf("expected the prompt to be refused, running=%v", m.running)
+	}
+	if !strings.Contains(m.status, "prompt is too long") {
+		t.Fatalf("status = %q, want a too-long error", m.status)
+	}
+	if m.mode != modeInput || m.input.Value() == "" {
+		t.Fatalf("expected to stay in input with the prompt kept, mode=%v", m.mode)
+	}
+}
+
+func TestModelWarnsOnLargePrompt(t *testing.T) {
+	m := newTestModel(t)
+	m.promptLimit = promptLimit{Warn: 50}
+	m, cmd := submit(t, m, strings.Repeat("word ", 40))
+	if cmd == nil || !m.running {
+		t.Fatal("expected a large prompt to still be sent")
+	}
+	if !strings.Contains(m.status, "large prompt") {
+		t.Fatalf("status = %q, want a size warning", m.status)
+	}
+}
+
+func TestModelExecTemplateWrapsValue(t *testing.T) {
+	m := newTestModel(t)
+	m.stayOpenExec = true
+	m.execTemplate = "echo wrapped {{value}}"
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	m = next.(model)
+	if !strings.Contains(m.status, "echo wrapped a") {
+		t.Fatalf("status = %q, want the wrapped command", m.status)
+	}
+	res, ok := cmd().(execResultMsg)
+	if !ok || strings.TrimSpace(res.output) != "wrapped a" {
+		t.Fatalf("exec result = %#v, want output from the wrapped command", res)
+	}
+}
+
+func TestModelExecTemplateWrapsEachMarkedValue(t *testing.T) {
+	m := newTestModel(t)
+	m.confirm = true
+	m.execTemplate = "time {{value}}"
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeySpace}) // a
+	m.selected = 2
+	m = update(t, m, tea.KeyMsg{Type: tea.KeySpace}) // c
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlR})
+	if m.pendingRun == nil {
+		t.Fatal("expected the run to wait for confirmation")
+	}
+	if want := chainCommands([]string{"time a", "time c"}, false); m.pendingRun.script != want {
+		t.Fatalf("script = %q, want %q", m.pendingRun.script, want)
+	}
+	if !strings.Contains(m.View(), "time c") {
+		t.Fatal("expected the confirm panel to show the wrapped command")
+	}
+}
+
+func TestModelCyclesOptionBlocks(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	reply := `{"options":[{"value":"draft","description":""}]} ` + threeOptions
+	m = update(t, m, responseMsg{output: []byte(reply), cli: "claude"})
+	if len(m.options) != 3 || !strings.Contains(m.status, "2 options blocks") {
+		t.Fatalf("expected the last block with a hint, got %d options, status %q", len(m.options), m.status)
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'b'}})
+	if len(m.options) != 1 || m.options[0].Value != "draft" || !strings.Contains(m.status, "1/2") {
+		t.Fatalf("expected the first block, got %+v, status %q", m.options, m.status)
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'b'}})
+	if len(m.options) != 3 || m.selectedValue() != "a" {
+		t.Fatalf("expected to wrap to the last block, got %+v", m.options)
+	}
+}
+
+func TestModelAutoSelectTagsRecommendation(t *testing.T) {
+	m := newTestModel(t)
+	m.autoSelect = true
+	m.sortBy = sortByIndex
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	if m.selectedValue() != "a" || m.suggested != m.selected || m.running {
+		t.Fatalf("expected the top recommendation selected without running, got %q", m.selectedValue())
+	}
+	if !strings.Contains(m.renderOptionsTable(), "a  "+icons.suggest+" suggested") {
+		t.Fatal("expected the suggested option to be tagged")
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyDown})
+	if !strings.Contains(m.renderOptionsTable(), icons.suggest+" suggested") {
+		t.Fatal("expected the tag to stay after moving the selection")
+	}
+}
+
+func TestRecommendedIndex(t *testing.T) {
+	opts := []optionEntry{{Value: "x"}, {Value: "y", RecommendationOrder: 3}, {Value: "z", RecommendationOrder: 1}}
+	if got := recommendedIndex(opts); got != 2 {
+		t.Fatalf("recommendedIndex = %d, want 2", got)
+	}
+	if got := recommendedIndex(opts[:1]); got != 0 {
+		t.Fatalf("unordered recommendedIndex = %d, want 0", got)
+	}
+	if got := recommendedIndex(nil); got != -1 {
+		t.Fatalf("empty recommendedIndex = %d, want -1", got)
+	}
+}
+
+func TestModelRendersExtraFields(t *testing.T) {
+	m := newTestModel(t)
+	m.extraFields = []extraField{{Field: "risk", Label: "Risk"}}
+	m, _ = submit(t, m, "clean up")
+	m = update(t, m, responseMsg{output: []byte(`{"options":[{"value":"make clean","description":"tidy","risk":"low"}]}`), cli: "claude"})
+	if table := m.renderOptionsTable(); !strings.Contains(table, "tidy [Risk: low]") {
+		t.Fatalf("expected the extra field after the description:\n%s", table)
+	}
+}
+
+func TestModelSwapsBackToLastCLI(t *testing.T) {
+	fake := func(ctx context.Context, prompt string, yolo bool) *exec.Cmd {
+		return exec.CommandContext(ctx, "true")
+	}
+	clis := []cliOption{{name: "claude", runPrompt: fake}, {name: "codex", runPrompt: fake}, {name: "gemini", runPrompt: fake}}
+	m := update(t, newModelWithCLIs(clis, "claude", false, false, config{}), tea.WindowSizeMsg{Width: 100, Height: 40})
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlL})
+	if m.currentCLI().name != "claude" || !strings.Contains(m.status, "no previous CLI") {
+		t.Fatalf("expected nothing to go back to, got %s (%q)", m.currentCLI().name, m.status)
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlN})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlN})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlL})
+	if m.currentCLI().name != "codex" {
+		t.Fatalf("expected ctrl+l to undo the overshoot, got %s", m.currentCLI().name)
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlL})
+	if m.currentCLI().name != "gemini" {
+		t.Fatalf("expected a second ctrl+l to swap back, got %s", m.currentCLI().name)
+	}
+
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "gemini"})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'-'}})
+	if m.currentCLI().name != "codex" {
+		t.Fatalf("expected - to go back in the results view, got %s", m.currentCLI().name)
+	}
+}
+
+func TestModelShowsAuthHint(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte("Invalid API key · Please run /login"), cli: "claude", err: errors.New("exit status 1")})
+	if !strings.Contains(m.status, "claude not authenticated") || m.lastError == nil {
+		t.Fatalf("expected an authentication hint, got %q", m.status)
+	}
+}
+
+func TestModelStickyPrompt(t *testing.T) {
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	if m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")}); m.input.Value() != "" {
+		t.Fatalf("expected a blank new prompt by default, got %q", m.input.Value())
+	}
+
+	m.stickyPrompt = true
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	if m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")}); m.input.Value() != "list files" || m.mode != modeInput {
+		t.Fatalf("expected the last prompt to carry over, got %q", m.input.Value())
+	}
+}
+
+func TestModelNamesTimeoutsAndCancellation(t *testing.T) {
+	m := newTestModel(t)
+	m.timeout = duration(90 * time.Second)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{cli: "claude", err: context.DeadlineExceeded})
+	if !strings.Contains(m.status, "claude timed out after 1m30s") || strings.Contains(m.status, "deadline") {
+		t.Fatalf("expected a timeout status, got %q", m.status)
+	}
+
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{cli: "claude", err: fmt.Errorf("run: %w", context.Canceled)})
+	if !strings.Contains(m.status, "claude run cancelled") {
+		t.Fatalf("expected a cancellation status, got %q", m.status)
+	}
+}
+
+func TestModelKeepOpenRunsAndExportCopiesCountAsActed(t *testing.T) {
+	defer func(r func() (string, error), w func(string) error) {
+		readClipboard, writeClipboard = r, w
+	}(readClipboard, writeClipboard)
+	readClipboard = func() (string, error) { return "", errors.New("unavailable") }
+	writeClipboard = func(string) error { return nil }
+
+	m := newTestModel(t)
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	if m = update(t, m, execResultMsg{output: "done"}); !m.acted {
+		t.Fatal("expected a finished stay-open run to count as acted")
+	}
+
+	for _, key := range []string{"m", "t", "Y", "D", "p"} {
+		m.acted = false
+		if m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}); !m.acted {
+			t.Errorf("expected the %s copy to count as acted, status %q", key, m.status)
+		}
+	}
+}
+
+func TestModelExportCopiesHonourAppend(t *testing.T) {
+	board := "earlier"
+	defer func(r func() (string, error), w func(string) error) {
+		readClipboard, writeClipboard = r, w
+	}(readClipboard, writeClipboard)
+	readClipboard = func() (string, error) { return board, nil }
+	writeClipboard = func(s string) error {
+		board = s
+		return nil
+	}
+
+	m := newTestModel(t)
+	m.appendClipboard = true
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("Y")})
+	if board != "earlier\na\nb\nc" || !strings.Contains(m.status, "Appended to clipboard: 3 values") {
+		t.Fatalf("expected the values appended, got %q (status %q)", board, m.status)
+	}
+
+	board = "earlier\n"
+	m.copyNewline = true
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("Y")})
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("Y")})
+	if board != "earlier\na\nb\nc\na\nb\nc\n" {
+		t.Fatalf("expected one newline between appends with copy_newline, got %q", board)
+	}
+}
+
+func TestViewShowsShortHelpStatus(t *testing.T) {
+	m := newTestModel(t)
+	view := m.View()
+	if !strings.Contains(view, "f1") || !strings.Contains(view, " for help") || strings.Contains(view, "toggle yolo") {
+		t.Fatalf("input view should show the short help status:\n%s", view)
+	}
+
+	m, _ = submit(t, m, "list files")
+	m = update(t, m, responseMsg{output: []byte(threeOptions), cli: "claude"})
+	view = m.View()
+	if !strings.Contains(view, "copy & exit") || !strings.Contains(view, " for help") || strings.Contains(view, "toggle yolo") {
+		t.Fatalf("viewing view should show the short help status:\n%s", view)
+	}
+}
+
+func TestModelTickInterval(t *testing.T) {
+	m := newModelWithCLIs(newTestModel(t).cliOptions, "claude", false, false, config{TickInterval: duration(time.Millisecond)})
+	cmd := m.startTicking()
+	start := time.Now()
+	if _, ok := cmd().(tickMsg); !ok {
+		t.Fatal("expected a tick message")
+	}
+	if elapsed := time.Since(start); elapsed >= defaultTickInterval {
+		t.Fatalf("expected the configured 1ms interval, took %v", elapsed)
+	}
+}
+
+func TestModelCLISwitching(t *testing.T) {
+	m := newTestModel(t)
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlN})
+	if m.currentCLI().name != "codex" {
+		t.Fatalf("expected codex after ctrl+n, got %s", m.currentCLI().name)
+	}
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyCtrlN})
+	if m.currentCLI().name != "claude" {
+		t.Fatalf("expected wraparound to claude, got %s", m.currentCLI().name)
+	}
+}